@@ -19,17 +19,172 @@ import (
 	"github.com/valyala/fasttemplate"
 )
 
+// anyOfType declares the JSON form of google.protobuf.Any, the fields of the
+// packed message T next to its type URL. Well-known types are packed as
+// AnyOf<{ value: V }>, V being what a field of the type is passed as.
+const anyOfType = `type AnyOf<T = {[key: string]: any}> = T & { '@type': string };
+
+`
+
+// grpcTypes declares the GrpcConfig module generated by protoc-gen-react, the
+// options of the calls and their errors.
+const grpcTypes = `/**
+ * Metadata maps the keys of headers or trailers to their values, base64 for
+ * -bin keys.
+ */
+interface Metadata {
+    [key: string]: string;
+}
+
+/**
+ * CallOptions are the options of a call. Compression is the name of a codec
+ * like gzip; the objc modules have no per-call compression and reject calls
+ * made with it with UNIMPLEMENTED. Calls made withMetadata resolve a
+ * WithMetadata. Unary calls made with a requestId are cancelled by
+ * cancel(requestId) and reject with CANCELLED, e.g. when an AbortSignal fires.
+ * A call made with the requestId of one in flight rejects with ALREADY_EXISTS.
+ */
+interface CallOptions {
+    deadlineMs?: number;
+    metadata?: Metadata;
+    compression?: string;
+    withMetadata?: boolean;
+    requestId?: string;
+}
+
+interface WithMetadata<T> {
+    response: T;
+    headers: Metadata;
+    trailers: Metadata;
+}
+
+/**
+ * StreamEvent is emitted under the id of a stream, the last one has done set
+ * and carries the trailers. Streams cancelled from javascript end with
+ * cancelled set instead.
+ */
+interface StreamEvent<T> {
+    done: boolean;
+    cancelled?: boolean;
+    data?: T;
+    error?: string;
+    code?: keyof typeof StatusCode;
+    userInfo?: GrpcErrorInfo;
+    trailers?: Metadata;
+}
+
+/**
+ * StreamError cancels a client or bidirectional stream from javascript. The
+ * last event of a bidirectional one carries the code, CANCELLED by default,
+ * and the message.
+ */
+interface StreamError {
+    code?: keyof typeof StatusCode;
+    message?: string;
+}
+
+/**
+ * StatusCode are the codes of gRPC statuses, StatusCode.js exports them.
+ */
+{{statusCode}}
+/**
+ * GrpcErrorInfo is the userInfo of a GrpcError. Details are the messages of
+ * the google.rpc.Status sent by the server, like google.rpc.BadRequest, with
+ * their type URL in '@type'. Only android decodes them.
+ */
+interface GrpcErrorInfo {
+    code: StatusCode;
+    description: string | null;
+    trailers: Metadata;
+    details: { '@type': string; [key: string]: any }[];
+}
+
+/**
+ * GrpcError is what calls reject with, its code is the name of the status
+ * code.
+ */
+interface GrpcError extends Error {
+    code: keyof typeof StatusCode;
+    userInfo: GrpcErrorInfo;
+}
+
+interface GrpcChannelOptions {
+    host?: string;
+    port?: number;
+    tls?: boolean;
+    authority?: string;
+    keepAliveMs?: number;
+    maxMessageSize?: number;
+}
+
+/**
+ * GrpcConfig configures the channels of a service given its full name, of the
+ * services of a proto package, or of every service given an empty name.
+ */
+interface GrpcConfig {
+    configure(name: string, options: GrpcChannelOptions): void;
+}
+
+`
+
+const grpcConfigModule = `// Code generated by protoc-gen-react-typings.
+// DO NOT EDIT!
+'use strict';
+
+import { NativeModules } from 'react-native';
+
+export const GrpcConfig = NativeModules.GrpcConfig;
+`
+
+const grpcConfigTurboModule = `// Code generated by protoc-gen-react-typings.
+// DO NOT EDIT!
+'use strict';
+
+import NativeGrpcConfig from './NativeGrpcConfig';
+
+export const GrpcConfig = NativeGrpcConfig;
+`
+
+const grpcConfigSpec = `// Code generated by protoc-gen-react-typings.
+// DO NOT EDIT!
+import type { TurboModule } from 'react-native';
+import { TurboModuleRegistry } from 'react-native';
+import type { UnsafeObject } from 'react-native/Libraries/Types/CodegenTypes';
+
+export interface Spec extends TurboModule {
+  configure(name: string, options: UnsafeObject): void;
+}
+
+export default TurboModuleRegistry.getEnforcing<Spec>('GrpcConfig');
+`
+
 var (
 	templateEnum = fasttemplate.New("export const {enumType}_{enumName} = {enumValue};\n", "{", "}")
 )
 
+// Options configures the code emitted by the generator.
+type Options struct {
+	// TurboModule emits Native<Service>.ts codegen specs and loads the
+	// services through TurboModuleRegistry instead of NativeModules.
+	TurboModule bool
+	// Int64 is how 64-bit integers cross the bridge, it has to match the
+	// int64 option of protoc-gen-react.
+	Int64 string
+	// Timestamp is how google.protobuf.Timestamp crosses the bridge, it has
+	// to match the timestamp option of protoc-gen-react.
+	Timestamp string
+}
+
 type generator struct {
 	reg *descriptor.Registry
+	// usesAny is set once a google.protobuf.Any field has been typed.
+	usesAny bool
+	Options
 }
 
 // New returns a new generator which generates grpc gateway files.
-func NewGenerator(reg *descriptor.Registry) gen.Generator {
-	return &generator{reg: reg}
+func NewGenerator(reg *descriptor.Registry, opts Options) gen.Generator {
+	return &generator{reg: reg, Options: opts}
 }
 
 func (g *generator) getFileName(file *descriptor.File) string {
@@ -82,22 +237,89 @@ func (g *generator) getTypeName(t desc.FieldDescriptorProto_Type, field *desc.Fi
 		return "boolean"
 	case desc.FieldDescriptorProto_TYPE_FIXED32, desc.FieldDescriptorProto_TYPE_DOUBLE:
 		return "number"
-	case desc.FieldDescriptorProto_TYPE_FLOAT, desc.FieldDescriptorProto_TYPE_INT32, desc.FieldDescriptorProto_TYPE_UINT32:
+	case desc.FieldDescriptorProto_TYPE_FLOAT, desc.FieldDescriptorProto_TYPE_INT32, desc.FieldDescriptorProto_TYPE_UINT32,
+		desc.FieldDescriptorProto_TYPE_SFIXED32, desc.FieldDescriptorProto_TYPE_SINT32:
 		return "number"
-	case desc.FieldDescriptorProto_TYPE_INT64, desc.FieldDescriptorProto_TYPE_FIXED64, desc.FieldDescriptorProto_TYPE_UINT64:
-		return "string|number"
+	case desc.FieldDescriptorProto_TYPE_INT64, desc.FieldDescriptorProto_TYPE_FIXED64, desc.FieldDescriptorProto_TYPE_UINT64,
+		desc.FieldDescriptorProto_TYPE_SFIXED64, desc.FieldDescriptorProto_TYPE_SINT64:
+		if g.Int64 == "number" {
+			return "number"
+		}
+		return "string"
 	case desc.FieldDescriptorProto_TYPE_BYTES:
 		return "string"
 	case desc.FieldDescriptorProto_TYPE_ENUM:
 		return "number"
 	//		return g.getRawTypeName(file, field.GetTypeName())
 	case desc.FieldDescriptorProto_TYPE_MESSAGE:
+		if tn, ok := g.wellKnownType(field.GetTypeName()); ok {
+			return tn
+		}
 		return g.getRawTypeName(file, field.GetTypeName())
 	default:
 		return "any"
 	}
 }
 
+// wellKnownType returns the type of the well-known message typeName, which
+// crosses the bridge in its proto3 JSON representation.
+func (g *generator) wellKnownType(typeName string) (string, bool) {
+	int64Type := "string"
+	if g.Int64 == "number" {
+		int64Type = "number"
+	}
+	switch typeName {
+	case ".google.protobuf.Timestamp":
+		if g.Timestamp == "ms" {
+			return "number", true
+		}
+		return "string", true
+	case ".google.protobuf.Duration":
+		return "string", true
+	case ".google.protobuf.Empty":
+		return "{}", true
+	case ".google.protobuf.Struct":
+		return "{[key: string]: any}", true
+	case ".google.protobuf.Value":
+		return "any", true
+	case ".google.protobuf.ListValue":
+		return "any[]", true
+	case ".google.protobuf.Any":
+		g.usesAny = true
+		return "AnyOf", true
+	case ".google.protobuf.DoubleValue", ".google.protobuf.FloatValue",
+		".google.protobuf.Int32Value", ".google.protobuf.UInt32Value":
+		return "number | null", true
+	case ".google.protobuf.Int64Value", ".google.protobuf.UInt64Value":
+		return int64Type + " | null", true
+	case ".google.protobuf.BoolValue":
+		return "boolean | null", true
+	case ".google.protobuf.StringValue", ".google.protobuf.BytesValue":
+		return "string | null", true
+	}
+	return "", false
+}
+
+// requestType returns the type of the requests of m.
+func (g *generator) requestType(m *descriptor.Method) string {
+	if tn, ok := g.wellKnownType(m.RequestType.FQMN()); ok {
+		return tn
+	}
+	return m.RequestType.GetName()
+}
+
+// responseType returns the type of the responses of m, Empty resolves with
+// nothing.
+func (g *generator) responseType(m *descriptor.Method) string {
+	if m.ResponseType.FQMN() == ".google.protobuf.Empty" {
+		return "void"
+	}
+	if tn, ok := g.wellKnownType(m.ResponseType.FQMN()); ok {
+		return tn
+	}
+	return m.ResponseType.GetName()
+}
+
 func (g *generator) isMap(field *desc.FieldDescriptorProto, file *descriptor.File) bool {
 	m, err := g.reg.LookupMsg(file.GetPackage(), field.GetTypeName())
 	if err != nil {
@@ -122,12 +344,12 @@ func (g *generator) printMessageField(w io.Writer, field *desc.FieldDescriptorPr
 		fasttemplate.Execute(`  {{fieldName}}: { [key: string]: {{valueType}} };
 		`, "{{", "}}", w, map[string]interface{}{
 			"fieldName": field.GetJsonName(),
-			"valueType": valueField.GetName(),
+			"valueType": g.getTypeName(valueField.GetType(), valueField, file),
 		})
 	} else if field.GetLabel() == desc.FieldDescriptorProto_LABEL_REPEATED {
 		tn := g.getTypeName(field.GetType(), field, file)
 		if strings.Index(tn, "|") > -1 {
-			tn = strings.Replace(tn, "|", "[]|", 1)
+			tn = "(" + tn + ")"
 		}
 		fmt.Fprintf(w, "  %s: %s[];\n", field.GetJsonName(), tn)
 	} else {
@@ -205,23 +427,12 @@ export function new[returnType](){
 	}
 
 	//methProtoPath := protoPathIndex(reflect.TypeOf((*desc.ServiceDescriptorProto)(nil)), "Method")
+	if len(file.Services) > 0 && !g.TurboModule {
+		fmt.Fprint(&buf, "\nimport { NativeModules } from 'react-native';\n")
+	}
 
 	for svcIdx, s := range file.Services {
-		tmpHeader := `
-import { NativeModules } from 'react-native';
-/**
- *
- * @returns {[serviceName]}
- */
-function __[serviceName]() {
-    return NativeModules.[serviceName];
-}
-
-export const [serviceName] = __[serviceName]();
-`
-		fasttemplate.Execute(tmpHeader, "[", "]", &buf, map[string]interface{}{
-			"serviceName": s.GetName(),
-		})
+		g.generateWrapper(s, &buf)
 
 		printComment(index, protoComments(g.reg, s.File, nil, "Service", int32(svcIdx)))
 
@@ -237,30 +448,50 @@ interface {{serviceName}} {
 			server := m.GetServerStreaming()
 			client := m.GetClientStreaming()
 			if !server && !client {
-				fasttemplate.Execute(`    {{methodName}}(req: {{requestType}}): Promise<{{responseType}}>;
+				fasttemplate.Execute(`    {{methodName}}(req: {{requestType}}, options: CallOptions & { withMetadata: true }): Promise<WithMetadata<{{responseType}}>>;
+    {{methodName}}(req: {{requestType}}, options?: CallOptions): Promise<{{responseType}}>;
 `, "{{", "}}", index, map[string]interface{}{
 					"methodName":   ToJsonName(m.GetName()),
-					"requestType":  m.RequestType.GetName(),
-					"responseType": m.ResponseType.GetName(),
+					"requestType":  g.requestType(m),
+					"responseType": g.responseType(m),
 				})
 			} else if server && client {
-				fasttemplate.Execute(`    start{{bigMethodName}}(): Promise<string>;
-	{{methodName}}(id: string, action: string, event: {{requestType}});
+				fasttemplate.Execute(`    start{{bigMethodName}}(options?: CallOptions): Promise<string>;
+	{{methodName}}(id: string, action: "error", event?: StreamError): Promise<void>;
+	{{methodName}}(id: string, action: "complete", event?: {{requestType}}): Promise<void>;
+	{{methodName}}(id: string, action: string, event: {{requestType}}): Promise<void>;
+`, "{{", "}}", index, map[string]interface{}{
+					"methodName":    ToJsonName(m.GetName()),
+					"bigMethodName": strings.Title(ToJsonName(m.GetName())),
+					"requestType":   g.requestType(m),
+					"responseType":  g.responseType(m),
+				})
+			} else if !server && client {
+				fasttemplate.Execute(`    start{{bigMethodName}}(options?: CallOptions): Promise<string>;
+	{{methodName}}(id: string, action: "error", event?: StreamError): Promise<void>;
+	{{methodName}}(id: string, action: "complete", event?: {{requestType}}): Promise<{{responseType}}>;
+	{{methodName}}(id: string, action: string, event: {{requestType}}): Promise<void>;
 `, "{{", "}}", index, map[string]interface{}{
 					"methodName":    ToJsonName(m.GetName()),
 					"bigMethodName": strings.Title(ToJsonName(m.GetName())),
-					"requestType":   m.RequestType.GetName(),
-					"responseType":  m.ResponseType.GetName(),
+					"requestType":   g.requestType(m),
+					"responseType":  g.responseType(m),
 				})
 			} else if server && !client {
-				fasttemplate.Execute(`    {{methodName}}(req: {{requestType}}): Promise<string>;
+				fasttemplate.Execute(`    {{methodName}}(req: {{requestType}}, options?: CallOptions): Promise<string>;
 `, "{{", "}}", index, map[string]interface{}{
 					"methodName":   ToJsonName(m.GetName()),
-					"requestType":  m.RequestType.GetName(),
-					"responseType": m.ResponseType.GetName(),
+					"requestType":  g.requestType(m),
+					"responseType": g.responseType(m),
 				})
 			}
 		}
+		if hasCancellable(s) {
+			fmt.Fprint(index, "    cancel(id: string): void;\n")
+		}
+		if hasBiStreaming(s) {
+			fmt.Fprint(index, "    /** Resolves the number of open bidirectional streams, for diagnostics. */\n    activeStreamCount(): Promise<number>;\n")
+		}
 		fmt.Fprint(index, `}
 `)
 
@@ -285,7 +516,121 @@ interface {{serviceName}} {
 	return buf.String(), nil
 }
 
+// generateWrapper writes the export of the module of svc. Native methods
+// can't be called with fewer arguments than they declare, so the wrapper
+// passes null for the arguments javascript leaves out.
+func (g *generator) generateWrapper(svc *descriptor.Service, buf io.Writer) {
+	name := svc.GetName()
+	native, constant := "NativeModules."+name, "native.NAME"
+	if g.TurboModule {
+		fmt.Fprintf(buf, "\nimport Native%s from './Native%s';\n", name, name)
+		native, constant = "Native"+name, "native.getConstants().NAME"
+	}
+	fasttemplate.Execute(`
+/**
+ *
+ * @returns {[serviceName]}
+ */
+function __[serviceName]() {
+    const native = [native];
+    return {
+        NAME: [constant],
+`, "[", "]", buf, map[string]interface{}{
+		"serviceName": name,
+		"native":      native,
+		"constant":    constant,
+	})
+	for _, m := range svc.Methods {
+		params := map[string]interface{}{
+			"methodName":    ToJsonName(m.GetName()),
+			"bigMethodName": strings.Title(ToJsonName(m.GetName())),
+		}
+		if m.GetClientStreaming() {
+			fasttemplate.Execute(`        start[bigMethodName]: (options) => native.start[bigMethodName](options || null),
+        [methodName]: (id, action, event) => native.[methodName](id, action, event || null),
+`, "[", "]", buf, params)
+		} else {
+			fasttemplate.Execute(`        [methodName]: (req, options) => native.[methodName](req, options || null),
+`, "[", "]", buf, params)
+		}
+	}
+	if hasCancellable(svc) {
+		fmt.Fprint(buf, "        cancel: (id) => native.cancel(id),\n")
+	}
+	if hasBiStreaming(svc) {
+		fmt.Fprint(buf, "        activeStreamCount: () => native.activeStreamCount(),\n")
+	}
+	fmt.Fprintf(buf, "    };\n}\n\nexport const %s = __%s();\n", name, name)
+}
+
+// generateSpec returns the TurboModule spec of svc. The React Native codegen
+// only understands types declared in the spec itself, so messages are passed
+// as UnsafeObject and typed by index.d.ts.
+func (g *generator) generateSpec(svc *descriptor.Service) string {
+	var buf bytes.Buffer
+	fmt.Fprint(&buf, `// Code generated by protoc-gen-react-typings.
+// DO NOT EDIT!
+import type { TurboModule } from 'react-native';
+import { TurboModuleRegistry } from 'react-native';
+import type { UnsafeObject } from 'react-native/Libraries/Types/CodegenTypes';
+
+export interface Spec extends TurboModule {
+  getConstants(): { NAME: string };
+`)
+	for _, m := range svc.Methods {
+		params := map[string]interface{}{
+			"methodName":    ToJsonName(m.GetName()),
+			"bigMethodName": strings.Title(ToJsonName(m.GetName())),
+		}
+		switch {
+		case m.GetClientStreaming() && m.GetServerStreaming():
+			fasttemplate.Execute(`  start{{bigMethodName}}(options: UnsafeObject | null): Promise<string>;
+  {{methodName}}(id: string, action: string, event: UnsafeObject | null): Promise<void>;
+`, "{{", "}}", &buf, params)
+		case m.GetClientStreaming():
+			fasttemplate.Execute(`  start{{bigMethodName}}(options: UnsafeObject | null): Promise<string>;
+  {{methodName}}(id: string, action: string, event: UnsafeObject | null): Promise<UnsafeObject | null>;
+`, "{{", "}}", &buf, params)
+		case m.GetServerStreaming():
+			fasttemplate.Execute(`  {{methodName}}(req: UnsafeObject, options: UnsafeObject | null): Promise<string>;
+`, "{{", "}}", &buf, params)
+		default:
+			fasttemplate.Execute(`  {{methodName}}(req: UnsafeObject, options: UnsafeObject | null): Promise<UnsafeObject>;
+`, "{{", "}}", &buf, params)
+		}
+	}
+	if hasCancellable(svc) {
+		fmt.Fprint(&buf, "  cancel(id: string): void;\n")
+	}
+	if hasBiStreaming(svc) {
+		fmt.Fprint(&buf, "  activeStreamCount(): Promise<number>;\n")
+	}
+	fmt.Fprintf(&buf, "}\n\nexport default TurboModuleRegistry.getEnforcing<Spec>('%s');\n", svc.GetName())
+	return buf.String()
+}
+
+// checkReservedNames fails when a method of the services of files is named
+// like one the native modules add to them: cancel for services with
+// cancellable calls and activeStreamCount for the ones with bidirectional
+// streams.
+func checkReservedNames(files []*descriptor.File) error {
+	for _, file := range files {
+		for _, svc := range file.Services {
+			reserved := map[string]bool{"cancel": hasCancellable(svc), "activeStreamCount": hasBiStreaming(svc)}
+			for _, m := range svc.Methods {
+				if name := ToJsonName(m.GetName()); reserved[name] {
+					return fmt.Errorf("%s: method %s of service %s clashes with the generated %s method, rename the rpc", file.GetName(), m.GetName(), svc.GetName(), name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func (g *generator) Generate(targets []*descriptor.File) ([]*plugin.CodeGeneratorResponse_File, error) {
+	if err := checkReservedNames(targets); err != nil {
+		return nil, err
+	}
 	var files []*plugin.CodeGeneratorResponse_File
 	var index bytes.Buffer
 	for _, file := range targets {
@@ -302,14 +647,125 @@ func (g *generator) Generate(targets []*descriptor.File) ([]*plugin.CodeGenerato
 			Content: proto.String(str),
 		})
 		glog.V(1).Infof("Will emit %s", output)
+		if !g.TurboModule {
+			continue
+		}
+		for _, svc := range file.Services {
+			output := filepath.Join(filepath.Dir(name), fmt.Sprintf("Native%s.ts", svc.GetName()))
+			files = append(files, &plugin.CodeGeneratorResponse_File{
+				Name:    proto.String(output),
+				Content: proto.String(g.generateSpec(svc)),
+			})
+			glog.V(1).Infof("Will emit %s", output)
+		}
+	}
+	content := index.String()
+	if hasServices(targets) {
+		content = strings.Replace(grpcTypes, "{{statusCode}}", statusCodeEnum("declare enum StatusCode {\n", "    %s = %d,\n", "}\n"), 1) + content
+		files = append(files, g.generateConfig()...)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String("StatusCode.js"),
+			Content: proto.String(statusCodeModule),
+		})
+	}
+	if g.usesAny {
+		content = anyOfType + content
 	}
 	files = append(files, &plugin.CodeGeneratorResponse_File{
 		Name:    proto.String("index.d.ts"),
-		Content: proto.String(index.String()),
+		Content: proto.String(content),
 	})
 	return files, nil
 }
 
+// generateConfig returns the GrpcConfig module, and its spec in turbomodule
+// mode.
+func (g *generator) generateConfig() []*plugin.CodeGeneratorResponse_File {
+	if !g.TurboModule {
+		return []*plugin.CodeGeneratorResponse_File{{
+			Name:    proto.String("GrpcConfig.js"),
+			Content: proto.String(grpcConfigModule),
+		}}
+	}
+	return []*plugin.CodeGeneratorResponse_File{{
+		Name:    proto.String("GrpcConfig.js"),
+		Content: proto.String(grpcConfigTurboModule),
+	}, {
+		Name:    proto.String("NativeGrpcConfig.ts"),
+		Content: proto.String(grpcConfigSpec),
+	}}
+}
+
+// statusCodes are the names of the gRPC status codes, by value.
+var statusCodes = []string{
+	"OK",
+	"CANCELLED",
+	"UNKNOWN",
+	"INVALID_ARGUMENT",
+	"DEADLINE_EXCEEDED",
+	"NOT_FOUND",
+	"ALREADY_EXISTS",
+	"PERMISSION_DENIED",
+	"RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION",
+	"ABORTED",
+	"OUT_OF_RANGE",
+	"UNIMPLEMENTED",
+	"INTERNAL",
+	"UNAVAILABLE",
+	"DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+var statusCodeModule = `// Code generated by protoc-gen-react-typings.
+// DO NOT EDIT!
+'use strict';
+
+export const StatusCode = Object.freeze(` + statusCodeEnum("{\n", "    %s: %d,\n", "}") + `);
+`
+
+// statusCodeEnum returns the status codes formatted by value between start
+// and end.
+func statusCodeEnum(start, value, end string) string {
+	var buf bytes.Buffer
+	buf.WriteString(start)
+	for i, name := range statusCodes {
+		fmt.Fprintf(&buf, value, name, i)
+	}
+	buf.WriteString(end)
+	return buf.String()
+}
+
+func hasServices(targets []*descriptor.File) bool {
+	for _, file := range targets {
+		if len(file.Services) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCancellable tells whether svc has unary or server streaming methods,
+// which can be cancelled from javascript.
+func hasCancellable(svc *descriptor.Service) bool {
+	for _, m := range svc.Methods {
+		if !m.GetClientStreaming() {
+			return true
+		}
+	}
+	return false
+}
+
+// hasBiStreaming tells whether svc has bidirectional streaming methods.
+func hasBiStreaming(svc *descriptor.Service) bool {
+	for _, m := range svc.Methods {
+		if m.GetClientStreaming() && m.GetServerStreaming() {
+			return true
+		}
+	}
+	return false
+}
+
 func protoComments(reg *descriptor.Registry, file *descriptor.File, outers []string, typeName string, typeIndex int32, fieldPaths ...int32) string {
 	if file.SourceCodeInfo == nil {
 		// Curious! A file without any source code info.
@@ -16,6 +16,9 @@ import (
 var (
 	importPrefix = flag.String("import_prefix", "", "prefix to be added to angular package paths for imported proto files")
 	file         = flag.String("file", "stdin", "where to load data from")
+	turboModule  = flag.Bool("turbomodule", false, "emit TurboModule specs for the New Architecture")
+	int64Mode    = flag.String("int64", "string", "how 64-bit integers are passed to javascript: string or number")
+	timestamp    = flag.String("timestamp", "iso", "how google.protobuf.Timestamp is passed to javascript: iso or ms")
 )
 
 func parseReq(r io.Reader) (*plugin.CodeGeneratorRequest, error) {
@@ -64,7 +67,11 @@ func main() {
 			}
 		}
 	}
-	g := NewGenerator(reg)
+	g := NewGenerator(reg, Options{
+		TurboModule: *turboModule,
+		Int64:       *int64Mode,
+		Timestamp:   *timestamp,
+	})
 
 	reg.SetPrefix(*importPrefix)
 	if err := reg.Load(req); err != nil {
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/grpc-ecosystem/grpc-gateway/protoc-gen-grpc-gateway/descriptor"
+	"github.com/valyala/fasttemplate"
+)
+
+const javaConvertersHeader = `// Code generated by protoc-gen-react
+// DO NOT EDIT!
+package {{packageName}};
+
+import com.facebook.react.bridge.*;
+
+import com.google.protobuf.ByteString;
+import java.util.ArrayList;
+import java.util.List;
+import java.util.Map;
+`
+
+// javaConverters is the <Package>Converters class holding the converters of
+// the messages of a proto package, shared by the modules of every file.
+// External classes are generated for their package in another run.
+type javaConverters struct {
+	name        string
+	javaPackage string
+	dir         string
+	external    bool
+	messages    []*descriptor.Message
+}
+
+// javaConvertersName returns the name of the converters class of the proto
+// package pkg, e.g. FooBarConverters for foo.bar.
+func javaConvertersName(pkg string) string {
+	name := ""
+	for _, part := range strings.FieldsFunc(pkg, func(r rune) bool { return r == '.' || r == '_' }) {
+		name += strings.Title(part)
+	}
+	return name + "Converters"
+}
+
+// moduleJavaPackage returns the java package the classes generated for file
+// are declared in.
+func (g *generator) moduleJavaPackage(file *descriptor.File) string {
+	if g.PackageName != "" {
+		return g.PackageName
+	}
+	return javaPackageOf(file)
+}
+
+// planConverters assigns every message of the targets, and every message
+// they use, to a converters class. The messages of google.protobuf have no
+// class of their own and are converted by the classes using them.
+func (g *generator) planConverters(targets []*descriptor.File) []*javaConverters {
+	g.converters = map[string]*javaConverters{}
+	var classes []*javaConverters
+	byPackage := map[string]*javaConverters{}
+	classOf := func(file *descriptor.File, external bool) *javaConverters {
+		c := byPackage[file.GetPackage()]
+		if c == nil {
+			c = &javaConverters{
+				name:        javaConvertersName(file.GetPackage()),
+				javaPackage: g.moduleJavaPackage(file),
+				dir:         ToFileName(filepath.Dir(file.GetName())),
+				external:    external,
+			}
+			byPackage[file.GetPackage()] = c
+			classes = append(classes, c)
+		}
+		return c
+	}
+	assign := func(c *javaConverters, m *descriptor.Message) {
+		c.messages = append(c.messages, m)
+		g.converters[m.FQMN()] = c
+	}
+	for _, file := range targets {
+		c := classOf(file, false)
+		for _, m := range file.Messages {
+			if !m.GetOptions().GetMapEntry() && !isWellKnownJSON(m.FQMN()) {
+				assign(c, m)
+			}
+		}
+	}
+	for _, file := range targets {
+		roots := append([]*descriptor.Message{}, file.Messages...)
+		for _, svc := range file.Services {
+			for _, m := range svc.Methods {
+				roots = append(roots, m.RequestType, m.ResponseType)
+			}
+		}
+		used, _ := g.collectMessages(roots)
+		for _, m := range used {
+			if g.converters[m.FQMN()] != nil {
+				continue
+			}
+			if m.File.GetPackage() == "google.protobuf" {
+				assign(classOf(file, false), m)
+			} else {
+				assign(classOf(m.File, true), m)
+			}
+		}
+	}
+	return classes
+}
+
+// javaConvertersPrefix returns the qualifier of calls to the converters of m,
+// empty inside the class holding them.
+func (g *generator) javaConvertersPrefix(m *descriptor.Message) string {
+	c := g.converters[m.FQMN()]
+	if c == nil || c == g.convertersClass {
+		return ""
+	}
+	return c.name + "."
+}
+
+// javaFromMap returns the method building m from a ReadableMap.
+func (g *generator) javaFromMap(m *descriptor.Message) string {
+	return g.javaConvertersPrefix(m) + g.javaFromMapName(m)
+}
+
+// javaToMap returns the method converting m to a WritableMap.
+func (g *generator) javaToMap(m *descriptor.Message) string {
+	return g.javaConvertersPrefix(m) + "toWritableMap"
+}
+
+// writeConvertersImports imports the converters classes of other java
+// packages, generated or external.
+func (g *generator) writeConvertersImports(javaPackage string, classes []*javaConverters, buf *bytes.Buffer) {
+	for _, c := range classes {
+		if c.javaPackage != javaPackage {
+			fmt.Fprintf(buf, "import %s.%s;\n", c.javaPackage, c.name)
+		}
+	}
+}
+
+func (g *generator) generateConverters(c *javaConverters, classes []*javaConverters) (string, error) {
+	var buf bytes.Buffer
+	g.convertersClass = c
+	defer func() { g.convertersClass = nil }()
+
+	fasttemplate.Execute(javaConvertersHeader, "{{", "}}", &buf, map[string]interface{}{
+		"packageName": c.javaPackage,
+	})
+	g.javaImports = map[string]bool{c.javaPackage: true}
+	for _, m := range c.messages {
+		pkg := javaPackageOf(m.File)
+		if !g.javaImports[pkg] && m.File.GetPackage() != "google.protobuf" {
+			g.javaImports[pkg] = true
+			fmt.Fprintf(&buf, "import %s.*;\n", pkg)
+		}
+	}
+	g.writeConvertersImports(c.javaPackage, classes, &buf)
+
+	fmt.Fprintf(&buf, "\npublic final class %s {\n    private %s() {\n    }\n", c.name, c.name)
+	usesAny := false
+	for _, m := range c.messages {
+		if err := g.writeConverters(m, &buf); err != nil {
+			return "", err
+		}
+		for _, f := range m.Fields {
+			usesAny = usesAny || f.GetTypeName() == wktAny
+		}
+	}
+	if usesAny {
+		var all []*descriptor.Message
+		for _, other := range classes {
+			all = append(all, other.messages...)
+		}
+		g.writeAnyRegistry(all, &buf)
+	}
+	g.writeHelpers(&buf)
+	buf.WriteString("}\n")
+	return buf.String(), nil
+}
+
+// generateJavaConverters returns the files of the converters classes.
+func (g *generator) generateJavaConverters(classes []*javaConverters) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	for _, c := range classes {
+		if c.external {
+			continue
+		}
+		str, err := g.generateConverters(c, classes)
+		if err != nil {
+			return nil, err
+		}
+		output := filepath.Join(c.dir, c.name+".java")
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(str),
+		})
+	}
+	return files, nil
+}
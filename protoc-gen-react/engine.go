@@ -0,0 +1,598 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/grpc-ecosystem/grpc-gateway/protoc-gen-grpc-gateway/descriptor"
+	"github.com/valyala/fasttemplate"
+)
+
+const (
+	grpcEngineTemplate = `// Code generated by protoc-gen-react
+// DO NOT EDIT!
+package {{packageName}};
+
+import io.grpc.ClientInterceptor;
+import io.grpc.ManagedChannel;
+import io.grpc.ManagedChannelBuilder;
+import io.grpc.Metadata;
+import io.grpc.stub.AbstractStub;
+import io.grpc.stub.MetadataUtils;
+import java.util.HashMap;
+import java.util.Map;
+import java.util.concurrent.TimeUnit;
+
+/**
+ * GrpcEngine gives the generated modules the channel of their service and the headers sent
+ * with every call. The channel of a service is configured by merging the default
+ * configuration, those of the proto packages enclosing the service and the one of the service
+ * itself, services with the same configuration share a channel.
+ */
+public class GrpcEngine {
+    /**
+     * ChannelConfig holds the settings of a channel, null fields are inherited.
+     */
+    public static class ChannelConfig {
+        public String host;
+        public Integer port;
+        public Boolean tls;
+        public String authority;
+        public Long keepAliveMillis;
+        public Integer maxMessageSize;
+
+        ChannelConfig merge(ChannelConfig over) {
+            ChannelConfig merged = new ChannelConfig();
+            merged.host = over.host != null ? over.host : host;
+            merged.port = over.port != null ? over.port : port;
+            merged.tls = over.tls != null ? over.tls : tls;
+            merged.authority = over.authority != null ? over.authority : authority;
+            merged.keepAliveMillis = over.keepAliveMillis != null ? over.keepAliveMillis : keepAliveMillis;
+            merged.maxMessageSize = over.maxMessageSize != null ? over.maxMessageSize : maxMessageSize;
+            return merged;
+        }
+
+        String key() {
+            return host + "|" + port + "|" + tls + "|" + authority + "|" + keepAliveMillis + "|" + maxMessageSize;
+        }
+    }
+
+    private final Map<String, ChannelConfig> configs = new HashMap<>();
+    private final Map<String, ManagedChannel> channels = new HashMap<>();
+    private final Map<String, String> headers = new HashMap<>();
+
+    public GrpcEngine(String host, int port, boolean tls) {
+        ChannelConfig config = new ChannelConfig();
+        config.host = host;
+        config.port = port;
+        config.tls = tls;
+        configs.put("", config);
+    }
+
+    /**
+     * Configures the channels of name, the full name of a service, e.g. FooGrpc.SERVICE_NAME,
+     * or a proto package. The empty name configures every service. The settings of config
+     * override those already set for name.
+     */
+    public synchronized void configure(String name, ChannelConfig config) {
+        ChannelConfig current = configs.get(name);
+        configs.put(name, current != null ? current.merge(config) : config);
+    }
+
+    private ChannelConfig configOf(String serviceName) {
+        ChannelConfig config = configs.get("");
+        for (int i = serviceName.indexOf('.'); i >= 0; i = serviceName.indexOf('.', i + 1)) {
+            ChannelConfig pkg = configs.get(serviceName.substring(0, i));
+            if (pkg != null) {
+                config = config.merge(pkg);
+            }
+        }
+        ChannelConfig service = configs.get(serviceName);
+        return service != null ? config.merge(service) : config;
+    }
+
+    public synchronized ManagedChannel byServiceName(String serviceName) {
+        ChannelConfig config = configOf(serviceName);
+        ManagedChannel channel = channels.get(config.key());
+        if (channel == null) {
+            ManagedChannelBuilder<?> builder = ManagedChannelBuilder.forAddress(config.host, config.port);
+            if (config.tls == null || !config.tls) {
+                builder.usePlaintext();
+            }
+            if (config.authority != null) {
+                builder.overrideAuthority(config.authority);
+            }
+            if (config.keepAliveMillis != null) {
+                builder.keepAliveTime(config.keepAliveMillis, TimeUnit.MILLISECONDS);
+            }
+            if (config.maxMessageSize != null) {
+                builder.maxInboundMessageSize(config.maxMessageSize);
+            }
+            channel = builder.build();
+            channels.put(config.key(), channel);
+        }
+        return channel;
+    }
+
+    public synchronized void setHeader(String key, String value) {
+        headers.put(key, value);
+    }
+
+    public synchronized void removeHeader(String key) {
+        headers.remove(key);
+    }
+
+    private synchronized ClientInterceptor headersInterceptor() {
+        Metadata metadata = new Metadata();
+        for (Map.Entry<String, String> header : headers.entrySet()) {
+            metadata.put(Metadata.Key.of(header.getKey(), Metadata.ASCII_STRING_MARSHALLER), header.getValue());
+        }
+        return MetadataUtils.newAttachHeadersInterceptor(metadata);
+    }
+
+    public <T extends AbstractStub<T>> T attachHeaders(T stub) {
+        return stub.withInterceptors(headersInterceptor());
+    }
+{{coroutineStubs}}
+    public synchronized void shutdown() {
+        for (ManagedChannel channel : channels.values()) {
+            channel.shutdown();
+        }
+        channels.clear();
+    }
+}
+`
+	grpcEngineCoroutineStubs = `
+    public <T extends io.grpc.kotlin.AbstractCoroutineStub<T>> T attachHeaders(T stub) {
+        return stub.withInterceptors(headersInterceptor());
+    }
+`
+	grpcPackageTemplate = `// Code generated by protoc-gen-react
+// DO NOT EDIT!
+package {{packageName}};
+
+import com.facebook.react.ReactPackage;
+import com.facebook.react.bridge.NativeModule;
+import com.facebook.react.bridge.ReactApplicationContext;
+import com.facebook.react.uimanager.ViewManager;
+import java.util.ArrayList;
+import java.util.Collections;
+import java.util.List;
+
+/**
+ * GeneratedGrpcPackage registers the GrpcConfig module configuring the channels of the engine,
+ * and the modules of every service generated with it. Register it once per engine.
+ */
+public class GeneratedGrpcPackage implements ReactPackage {
+    private final GrpcEngine engine;
+
+    public GeneratedGrpcPackage(GrpcEngine engine) {
+        this.engine = engine;
+    }
+
+    @Override
+    public List<NativeModule> createNativeModules(ReactApplicationContext reactContext) {
+        List<NativeModule> modules = new ArrayList<>();
+{{modules}}        return modules;
+    }
+
+    @Override
+    public List<ViewManager> createViewManagers(ReactApplicationContext reactContext) {
+        return Collections.emptyList();
+    }
+}
+`
+	grpcTurboPackageTemplate = `// Code generated by protoc-gen-react
+// DO NOT EDIT!
+package {{packageName}};
+
+import com.facebook.react.BaseReactPackage;
+import com.facebook.react.bridge.NativeModule;
+import com.facebook.react.bridge.ReactApplicationContext;
+import com.facebook.react.module.model.ReactModuleInfo;
+import com.facebook.react.module.model.ReactModuleInfoProvider;
+import java.util.HashMap;
+import java.util.Map;
+import javax.annotation.Nullable;
+
+/**
+ * GeneratedGrpcPackage registers the GrpcConfig module configuring the channels of the engine,
+ * and the modules of every service generated with it, as turbo modules. Register it once per
+ * engine.
+ */
+public class GeneratedGrpcPackage extends BaseReactPackage {
+    private final GrpcEngine engine;
+
+    public GeneratedGrpcPackage(GrpcEngine engine) {
+        this.engine = engine;
+    }
+
+    @Nullable
+    @Override
+    public NativeModule getModule(String name, ReactApplicationContext reactContext) {
+        switch (name) {
+{{cases}}            default:
+                return null;
+        }
+    }
+
+    @Override
+    public ReactModuleInfoProvider getReactModuleInfoProvider() {
+        return () -> {
+            Map<String, ReactModuleInfo> infos = new HashMap<>();
+{{infos}}            return infos;
+        };
+    }
+}
+`
+	grpcConfigModuleTemplate = `// Code generated by protoc-gen-react
+// DO NOT EDIT!
+package {{packageName}};
+
+import com.facebook.react.bridge.ReactApplicationContext;
+{{baseImport}}
+import com.facebook.react.bridge.ReactMethod;
+import com.facebook.react.bridge.ReadableMap;
+
+/**
+ * GrpcConfigModule lets javascript configure the channels of the engine, see
+ * {@link GrpcEngine#configure}.
+ */
+class GrpcConfigModule extends {{baseClass}} {
+    private final GrpcEngine engine;
+
+    GrpcConfigModule(ReactApplicationContext reactContext, GrpcEngine engine) {
+        super(reactContext);
+        this.engine = engine;
+    }
+
+    @Override
+    public String getName() {
+        return "GrpcConfig";
+    }
+
+    @ReactMethod
+{{override}}    public void configure(String name, ReadableMap options) {
+        GrpcEngine.ChannelConfig config = new GrpcEngine.ChannelConfig();
+        if (options.hasKey("host") && !options.isNull("host")) {
+            config.host = options.getString("host");
+        }
+        if (options.hasKey("port") && !options.isNull("port")) {
+            config.port = options.getInt("port");
+        }
+        if (options.hasKey("tls") && !options.isNull("tls")) {
+            config.tls = options.getBoolean("tls");
+        }
+        if (options.hasKey("authority") && !options.isNull("authority")) {
+            config.authority = options.getString("authority");
+        }
+        if (options.hasKey("keepAliveMs") && !options.isNull("keepAliveMs")) {
+            config.keepAliveMillis = (long) options.getDouble("keepAliveMs");
+        }
+        if (options.hasKey("maxMessageSize") && !options.isNull("maxMessageSize")) {
+            config.maxMessageSize = options.getInt("maxMessageSize");
+        }
+        engine.configure(name, config);
+    }
+}
+`
+	grpcCallOptionsTemplate = `// Code generated by protoc-gen-react
+// DO NOT EDIT!
+package {{packageName}};
+
+import android.util.Base64;
+import com.facebook.react.bridge.Arguments;
+import com.facebook.react.bridge.ReadableMap;
+import com.facebook.react.bridge.ReadableMapKeySetIterator;
+import com.facebook.react.bridge.WritableMap;
+import io.grpc.CallOptions;
+import io.grpc.Channel;
+import io.grpc.ClientCall;
+import io.grpc.ClientInterceptor;
+import io.grpc.Metadata;
+import io.grpc.MethodDescriptor;
+import io.grpc.stub.AbstractStub;
+import io.grpc.stub.MetadataUtils;
+import java.util.concurrent.TimeUnit;
+import java.util.concurrent.atomic.AtomicReference;
+import javax.annotation.Nullable;
+
+/**
+ * GrpcCallOptions applies the options javascript passes to a call: a deadline in deadlineMs,
+ * metadata, whose -bin values are base64, and the name of a compression codec. With
+ * withMetadata, calls resolve {response, headers, trailers} instead of the response. Unary
+ * calls made with a requestId can be cancelled under it.
+ */
+public final class GrpcCallOptions {
+    private GrpcCallOptions() {
+    }
+
+    /**
+     * Capture records the headers and trailers a call is answered with.
+     */
+    public static final class Capture {
+        private final AtomicReference<Metadata> headers = new AtomicReference<>();
+        private final AtomicReference<Metadata> trailers = new AtomicReference<>();
+
+        public ClientInterceptor interceptor() {
+            return MetadataUtils.newCaptureMetadataInterceptor(headers, trailers);
+        }
+
+        public WritableMap trailers() {
+            return toMap(trailers.get());
+        }
+
+        public WritableMap wrap(@Nullable WritableMap response) {
+            WritableMap out = Arguments.createMap();
+            out.putMap("response", response);
+            out.putMap("headers", toMap(headers.get()));
+            out.putMap("trailers", toMap(trailers.get()));
+            return out;
+        }
+    }
+
+    public static boolean withMetadata(@Nullable ReadableMap options) {
+        return options != null && options.hasKey("withMetadata") && !options.isNull("withMetadata")
+                && options.getBoolean("withMetadata");
+    }
+
+    @Nullable
+    public static String requestId(@Nullable ReadableMap options) {
+        if (options == null || !options.hasKey("requestId") || options.isNull("requestId")) {
+            return null;
+        }
+        return options.getString("requestId");
+    }
+
+    public static <T extends AbstractStub<T>> T apply(T stub, @Nullable ReadableMap options) {
+        if (options == null) {
+            return stub;
+        }
+        if (options.hasKey("deadlineMs") && !options.isNull("deadlineMs")) {
+            stub = stub.withDeadlineAfter((long) options.getDouble("deadlineMs"), TimeUnit.MILLISECONDS);
+        }
+        if (options.hasKey("metadata") && !options.isNull("metadata")) {
+            stub = stub.withInterceptors(MetadataUtils.newAttachHeadersInterceptor(metadata(options.getMap("metadata"))));
+        }
+        if (options.hasKey("compression") && !options.isNull("compression")) {
+            stub = stub.withCompression(options.getString("compression"));
+        }
+        return stub;
+    }
+{{coroutineStubs}}
+    public static Metadata metadata(ReadableMap map) {
+        Metadata metadata = new Metadata();
+        ReadableMapKeySetIterator iter = map.keySetIterator();
+        while (iter.hasNextKey()) {
+            String key = iter.nextKey();
+            if (key.endsWith(Metadata.BINARY_HEADER_SUFFIX)) {
+                metadata.put(Metadata.Key.of(key, Metadata.BINARY_BYTE_MARSHALLER), Base64.decode(map.getString(key), Base64.DEFAULT));
+            } else {
+                metadata.put(Metadata.Key.of(key, Metadata.ASCII_STRING_MARSHALLER), map.getString(key));
+            }
+        }
+        return metadata;
+    }
+
+    public static WritableMap toMap(@Nullable Metadata metadata) {
+        WritableMap out = Arguments.createMap();
+        if (metadata == null) {
+            return out;
+        }
+        for (String key : metadata.keys()) {
+            if (key.endsWith(Metadata.BINARY_HEADER_SUFFIX)) {
+                byte[] value = metadata.get(Metadata.Key.of(key, Metadata.BINARY_BYTE_MARSHALLER));
+                out.putString(key, Base64.encodeToString(value, Base64.NO_WRAP));
+            } else {
+                out.putString(key, metadata.get(Metadata.Key.of(key, Metadata.ASCII_STRING_MARSHALLER)));
+            }
+        }
+        return out;
+    }
+}
+`
+	grpcCallOptionsCoroutineStubs = `
+    public static <T extends io.grpc.kotlin.AbstractCoroutineStub<T>> T apply(T stub, @Nullable ReadableMap options) {
+        if (options == null) {
+            return stub;
+        }
+        if (options.hasKey("deadlineMs") && !options.isNull("deadlineMs")) {
+            stub = stub.withDeadlineAfter((long) options.getDouble("deadlineMs"), TimeUnit.MILLISECONDS);
+        }
+        if (options.hasKey("metadata") && !options.isNull("metadata")) {
+            stub = stub.withInterceptors(MetadataUtils.newAttachHeadersInterceptor(metadata(options.getMap("metadata"))));
+        }
+        if (options.hasKey("compression") && !options.isNull("compression")) {
+            stub = stub.withInterceptors(compression(options.getString("compression")));
+        }
+        return stub;
+    }
+
+    // Coroutine stubs have no withCompression, the codec is set on the options of each call.
+    private static ClientInterceptor compression(final String name) {
+        return new ClientInterceptor() {
+            @Override
+            public <ReqT, RespT> ClientCall<ReqT, RespT> interceptCall(MethodDescriptor<ReqT, RespT> method, CallOptions callOptions, Channel next) {
+                return next.newCall(method, callOptions.withCompression(name));
+            }
+        };
+    }
+`
+	objcConfigTemplate = `// Code generated by protoc-gen-react
+// DO NOT EDIT!
+#import <Foundation/Foundation.h>
+#import <React/RCTBridgeModule.h>
+
+#import "GrpcEngine.h"
+
+@interface GrpcConfigModule : NSObject <RCTBridgeModule>
+@end
+
+@implementation GrpcConfigModule
+
+RCT_EXPORT_MODULE(GrpcConfig)
+
++ (BOOL)requiresMainQueueSetup
+{
+    return NO;
+}
+
+RCT_EXPORT_METHOD(configure:(NSString *)name options:(NSDictionary *)options)
+{
+    [[GrpcEngine sharedEngine] configure:name options:options];
+}
+
+@end
+`
+	swiftConfigTemplate = `// Code generated by protoc-gen-react
+// DO NOT EDIT!
+import Foundation
+import React
+
+@objc(GrpcConfigModule)
+class GrpcConfigModule: NSObject {
+    @objc static func requiresMainQueueSetup() -> Bool {
+        return false
+    }
+
+    @objc(configure:options:)
+    func configure(_ name: String, options: [String: Any]) {
+        GrpcEngine.shared.configure(name, options: options)
+    }
+}
+`
+	swiftConfigBridgeTemplate = `// Code generated by protoc-gen-react
+// DO NOT EDIT!
+#import <React/RCTBridgeModule.h>
+
+@interface RCT_EXTERN_REMAP_MODULE(GrpcConfig, GrpcConfigModule, NSObject)
+RCT_EXTERN_METHOD(configure:(NSString *)name options:(NSDictionary *)options)
+@end
+`
+)
+
+// generateEngine returns a GrpcEngine, its GrpcConfigModule, GrpcCallOptions,
+// GrpcErrors and a GeneratedGrpcPackage registering the config module and the
+// modules of every file, once for the whole request, in the engine package. They are java for
+// kotlin modules too.
+func (g *generator) generateEngine(files []*descriptor.File) []*plugin.CodeGeneratorResponse_File {
+	if len(files) == 0 {
+		return nil
+	}
+	// The classes are written next to the first module of their package, or
+	// under the path of the package when no module is declared in it.
+	pn, dir := g.enginePackage, ""
+	// GrpcConfig is registered once, with the engine it configures, ahead of
+	// the service modules.
+	names, classes := []string{"GrpcConfig"}, []string{"GrpcConfigModule"}
+	for _, file := range files {
+		prefix := ""
+		if mp := g.moduleJavaPackage(file); mp != pn {
+			prefix = mp + "."
+		} else if dir == "" {
+			dir = ToFileName(filepath.Dir(file.GetName()))
+		}
+		for _, svc := range file.Services {
+			names = append(names, svc.GetName())
+			classes = append(classes, prefix+svc.GetName()+"Module")
+		}
+	}
+	if dir == "" {
+		dir = strings.Replace(pn, ".", "/", -1)
+	}
+	var modules, cases, infos bytes.Buffer
+	for i, class := range classes {
+		fmt.Fprintf(&modules, "        modules.add(new %s(reactContext, engine));\n", class)
+		fmt.Fprintf(&cases, "            case %q:\n                return new %s(reactContext, engine);\n", names[i], class)
+		fmt.Fprintf(&infos, "            infos.put(%q, new ReactModuleInfo(%q, %q, false, false, false, true));\n", names[i], names[i], class)
+	}
+
+	coroutineStubs, coroutineOptions := "", ""
+	if g.Lang == "kotlin" {
+		coroutineStubs, coroutineOptions = grpcEngineCoroutineStubs, grpcCallOptionsCoroutineStubs
+	}
+	engine := fasttemplate.ExecuteString(grpcEngineTemplate, "{{", "}}", map[string]interface{}{
+		"packageName":    pn,
+		"coroutineStubs": coroutineStubs,
+	})
+	pkgTemplate, baseClass, baseImport, override := grpcPackageTemplate, "ReactContextBaseJavaModule", "com.facebook.react.bridge", ""
+	if g.TurboModule {
+		// Turbo modules are looked up by name, and GrpcConfig implements
+		// the NativeGrpcConfig spec of the typings.
+		pkgTemplate, baseClass, baseImport = grpcTurboPackageTemplate, "NativeGrpcConfigSpec", g.SpecPackage
+		override = "    @Override\n"
+	}
+	pkg := fasttemplate.ExecuteString(pkgTemplate, "{{", "}}", map[string]interface{}{
+		"packageName": pn,
+		"modules":     modules.String(),
+		"cases":       cases.String(),
+		"infos":       infos.String(),
+	})
+	config := fasttemplate.ExecuteString(grpcConfigModuleTemplate, "{{", "}}", map[string]interface{}{
+		"packageName": pn,
+		"baseClass":   baseClass,
+		"baseImport":  fmt.Sprintf("import %s.%s;", baseImport, baseClass),
+		"override":    override,
+	})
+	options := fasttemplate.ExecuteString(grpcCallOptionsTemplate, "{{", "}}", map[string]interface{}{
+		"packageName":    pn,
+		"coroutineStubs": coroutineOptions,
+	})
+	var out []*plugin.CodeGeneratorResponse_File
+	for _, f := range [][2]string{
+		{"GrpcEngine.java", engine},
+		{"GrpcConfigModule.java", config},
+		{"GrpcCallOptions.java", options},
+		{"GrpcErrors.java", g.generateErrors(pn)},
+		{"GeneratedGrpcPackage.java", pkg},
+	} {
+		output := filepath.Join(dir, f[0])
+		out = append(out, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(f[1]),
+		})
+		glog.V(1).Infof("Will emit %s", output)
+	}
+	return out
+}
+
+// writeEngineImports imports the engine classes into the modules of the java
+// package javaPackage, ending the lines with end.
+func (g *generator) writeEngineImports(javaPackage, end string, buf *bytes.Buffer) {
+	if javaPackage == g.enginePackage {
+		return
+	}
+	for _, class := range []string{"GrpcCallOptions", "GrpcEngine", "GrpcErrors"} {
+		fmt.Fprintf(buf, "import %s.%s%s\n", g.enginePackage, class, end)
+	}
+}
+
+// generateAppleConfig returns the GrpcConfig module of the objc and swift
+// targets, written next to the first of files. It hands the options to the
+// configure method of the GrpcEngine of the application, as they are given
+// to the GrpcConfigModule of android.
+func (g *generator) generateAppleConfig(files []*descriptor.File) []*plugin.CodeGeneratorResponse_File {
+	if len(files) == 0 {
+		return nil
+	}
+	contents := [][2]string{{"GrpcConfigModule.m", objcConfigTemplate}}
+	if g.Target == "swift" {
+		contents = [][2]string{{"GrpcConfigModule.swift", swiftConfigTemplate}, {"GrpcConfigModule.m", swiftConfigBridgeTemplate}}
+	}
+	dir := ToFileName(filepath.Dir(files[0].GetName()))
+	var out []*plugin.CodeGeneratorResponse_File
+	for _, f := range contents {
+		output := filepath.Join(dir, f[0])
+		out = append(out, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(f[1]),
+		})
+		glog.V(1).Infof("Will emit %s", output)
+	}
+	return out
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/valyala/fasttemplate"
+)
+
+const grpcErrorsTemplate = `// Code generated by protoc-gen-react
+// DO NOT EDIT!
+package {{packageName}};
+
+{{imports}}
+/**
+ * GrpcErrors turns the failures of calls into errors javascript can tell apart. Their code is
+ * the name of the status code and their userInfo holds the numeric code, the description, the
+ * trailers and, with the full protobuf runtime, the details of the google.rpc.Status sent in
+ * grpc-status-details-bin, which need com.google.api.grpc:proto-google-common-protos. Calls
+ * cancelled from javascript fail with CANCELLED.
+ */
+public final class GrpcErrors {
+    private GrpcErrors() {
+    }
+
+    public static void reject(Promise promise, Throwable t) {
+        if (t instanceof CancellationException) {
+            t = Status.CANCELLED.withDescription("cancelled by javascript").withCause(t).asRuntimeException();
+        }
+        Status status = Status.fromThrowable(t);
+        promise.reject(status.getCode().name(), t.getMessage(), t, userInfo(status, Status.trailersFromThrowable(t)));
+    }
+
+    /**
+     * Rejects a unary call made with the requestId of one still in flight, which stays
+     * cancellable under it.
+     */
+    public static void rejectInFlight(Promise promise, String requestId) {
+        reject(promise, Status.ALREADY_EXISTS.withDescription("a call with requestId " + requestId + " is in flight")
+                .asRuntimeException());
+    }
+
+    /**
+     * Rejects a call javascript gave an invalid message with INVALID_ARGUMENT.
+     */
+    public static void rejectInvalid(Promise promise, String message) {
+        reject(promise, Status.INVALID_ARGUMENT.withDescription(message).asRuntimeException());
+    }
+
+    /**
+     * Returns the error javascript ends a stream with: the status named by the code of event,
+     * CANCELLED by default, described by its message.
+     */
+    public static StatusRuntimeException fromJS(@Nullable ReadableMap event) {
+        Status status = Status.CANCELLED;
+        if (event != null && event.hasKey("code") && !event.isNull("code")) {
+            try {
+                status = Status.fromCode(Status.Code.valueOf(event.getString("code")));
+            } catch (IllegalArgumentException e) {
+                status = Status.UNKNOWN;
+            }
+        }
+        String message = "cancelled by javascript";
+        if (event != null && event.hasKey("message") && !event.isNull("message")) {
+            message = event.getString("message");
+        }
+        return status.withDescription(message).asRuntimeException();
+    }
+
+    /**
+     * Sets the error, code and userInfo of the event ending a stream.
+     */
+    public static void putError(WritableMap event, Throwable t) {
+        Status status = Status.fromThrowable(t);
+        event.putString("error", t.getMessage());
+        event.putString("code", status.getCode().name());
+        event.putMap("userInfo", userInfo(status, Status.trailersFromThrowable(t)));
+    }
+
+    public static WritableMap userInfo(Status status, @Nullable Metadata trailers) {
+        WritableMap out = Arguments.createMap();
+        out.putInt("code", status.getCode().value());
+        out.putString("description", status.getDescription());
+        out.putMap("trailers", GrpcCallOptions.toMap(trailers));
+        out.putArray("details", {{details}});
+        return out;
+    }
+{{detailsMethods}}}
+`
+
+// grpcErrorsDetails decode the google.rpc.Status details of the full runtime,
+// the lite one leaves them empty as the common protos are not built for it.
+const grpcErrorsDetails = `
+    private static final Metadata.Key<byte[]> STATUS_DETAILS_KEY =
+            Metadata.Key.of("grpc-status-details-bin", Metadata.BINARY_BYTE_MARSHALLER);
+
+    private static WritableArray details(@Nullable Metadata trailers) {
+        WritableArray out = Arguments.createArray();
+        byte[] bytes = trailers != null ? trailers.get(STATUS_DETAILS_KEY) : null;
+        if (bytes == null) {
+            return out;
+        }
+        try {
+            for (Any detail : com.google.rpc.Status.parseFrom(bytes).getDetailsList()) {
+                out.pushMap(detail(detail));
+            }
+        } catch (InvalidProtocolBufferException e) {
+            // The details are left out, the status still tells what failed.
+        }
+        return out;
+    }
+
+    private static WritableMap detail(Any any) throws InvalidProtocolBufferException {
+        WritableMap out = Arguments.createMap();
+        String typeUrl = any.getTypeUrl();
+        out.putString("@type", typeUrl);
+        switch (typeUrl.substring(typeUrl.lastIndexOf('/') + 1)) {
+            case "google.rpc.ErrorInfo": {
+                ErrorInfo info = ErrorInfo.parseFrom(any.getValue());
+                out.putString("reason", info.getReason());
+                out.putString("domain", info.getDomain());
+                WritableMap metadata = Arguments.createMap();
+                for (Map.Entry<String, String> entry : info.getMetadataMap().entrySet()) {
+                    metadata.putString(entry.getKey(), entry.getValue());
+                }
+                out.putMap("metadata", metadata);
+                break;
+            }
+            case "google.rpc.RetryInfo": {
+                RetryInfo info = RetryInfo.parseFrom(any.getValue());
+                out.putString("retryDelay", duration(info.getRetryDelay()));
+                break;
+            }
+            case "google.rpc.DebugInfo": {
+                DebugInfo info = DebugInfo.parseFrom(any.getValue());
+                WritableArray entries = Arguments.createArray();
+                for (String entry : info.getStackEntriesList()) {
+                    entries.pushString(entry);
+                }
+                out.putArray("stackEntries", entries);
+                out.putString("detail", info.getDetail());
+                break;
+            }
+            case "google.rpc.QuotaFailure": {
+                WritableArray violations = Arguments.createArray();
+                for (QuotaFailure.Violation v : QuotaFailure.parseFrom(any.getValue()).getViolationsList()) {
+                    WritableMap violation = Arguments.createMap();
+                    violation.putString("subject", v.getSubject());
+                    violation.putString("description", v.getDescription());
+                    violations.pushMap(violation);
+                }
+                out.putArray("violations", violations);
+                break;
+            }
+            case "google.rpc.PreconditionFailure": {
+                WritableArray violations = Arguments.createArray();
+                for (PreconditionFailure.Violation v : PreconditionFailure.parseFrom(any.getValue()).getViolationsList()) {
+                    WritableMap violation = Arguments.createMap();
+                    violation.putString("type", v.getType());
+                    violation.putString("subject", v.getSubject());
+                    violation.putString("description", v.getDescription());
+                    violations.pushMap(violation);
+                }
+                out.putArray("violations", violations);
+                break;
+            }
+            case "google.rpc.BadRequest": {
+                WritableArray violations = Arguments.createArray();
+                for (BadRequest.FieldViolation v : BadRequest.parseFrom(any.getValue()).getFieldViolationsList()) {
+                    WritableMap violation = Arguments.createMap();
+                    violation.putString("field", v.getField());
+                    violation.putString("description", v.getDescription());
+                    violations.pushMap(violation);
+                }
+                out.putArray("fieldViolations", violations);
+                break;
+            }
+            case "google.rpc.RequestInfo": {
+                RequestInfo info = RequestInfo.parseFrom(any.getValue());
+                out.putString("requestId", info.getRequestId());
+                out.putString("servingData", info.getServingData());
+                break;
+            }
+            case "google.rpc.ResourceInfo": {
+                ResourceInfo info = ResourceInfo.parseFrom(any.getValue());
+                out.putString("resourceType", info.getResourceType());
+                out.putString("resourceName", info.getResourceName());
+                out.putString("owner", info.getOwner());
+                out.putString("description", info.getDescription());
+                break;
+            }
+            case "google.rpc.Help": {
+                WritableArray links = Arguments.createArray();
+                for (Help.Link l : Help.parseFrom(any.getValue()).getLinksList()) {
+                    WritableMap link = Arguments.createMap();
+                    link.putString("description", l.getDescription());
+                    link.putString("url", l.getUrl());
+                    links.pushMap(link);
+                }
+                out.putArray("links", links);
+                break;
+            }
+            case "google.rpc.LocalizedMessage": {
+                LocalizedMessage message = LocalizedMessage.parseFrom(any.getValue());
+                out.putString("locale", message.getLocale());
+                out.putString("message", message.getMessage());
+                break;
+            }
+            default:
+                out.putString("value", Base64.encodeToString(any.getValue().toByteArray(), Base64.NO_WRAP));
+                break;
+        }
+        return out;
+    }
+
+    private static String duration(com.google.protobuf.Duration value) {
+        if (value.getNanos() == 0) {
+            return value.getSeconds() + "s";
+        }
+        return String.format(Locale.US, "%d.%09ds", value.getSeconds(), Math.abs(value.getNanos()));
+    }
+`
+
+var (
+	grpcErrorsImports = []string{
+		"com.facebook.react.bridge.Arguments",
+		"com.facebook.react.bridge.Promise",
+		"com.facebook.react.bridge.ReadableMap",
+		"com.facebook.react.bridge.WritableMap",
+		"io.grpc.Metadata",
+		"io.grpc.Status",
+		"io.grpc.StatusRuntimeException",
+		"java.util.concurrent.CancellationException",
+		"javax.annotation.Nullable",
+	}
+	grpcErrorsDetailsImports = []string{
+		"android.util.Base64",
+		"com.facebook.react.bridge.WritableArray",
+		"com.google.protobuf.Any",
+		"com.google.protobuf.InvalidProtocolBufferException",
+		"com.google.rpc.BadRequest",
+		"com.google.rpc.DebugInfo",
+		"com.google.rpc.ErrorInfo",
+		"com.google.rpc.Help",
+		"com.google.rpc.LocalizedMessage",
+		"com.google.rpc.PreconditionFailure",
+		"com.google.rpc.QuotaFailure",
+		"com.google.rpc.RequestInfo",
+		"com.google.rpc.ResourceInfo",
+		"com.google.rpc.RetryInfo",
+		"java.util.Locale",
+		"java.util.Map",
+	}
+)
+
+// generateErrors returns the GrpcErrors class of the java package pn. The
+// details of errors are only decoded for the full runtime.
+func (g *generator) generateErrors(pn string) string {
+	imports := append([]string{}, grpcErrorsImports...)
+	details, detailsMethods := "Arguments.createArray()", ""
+	if g.Runtime != "lite" {
+		imports = append(imports, grpcErrorsDetailsImports...)
+		details, detailsMethods = "details(trailers)", grpcErrorsDetails
+	}
+	sort.Strings(imports)
+	var buf bytes.Buffer
+	for _, imp := range imports {
+		fmt.Fprintf(&buf, "import %s;\n", imp)
+	}
+	return fasttemplate.ExecuteString(grpcErrorsTemplate, "{{", "}}", map[string]interface{}{
+		"packageName":    pn,
+		"imports":        buf.String(),
+		"details":        details,
+		"detailsMethods": detailsMethods,
+	})
+}
+
+// objcErrorHelpers reject with, and send in stream events, the name of the
+// status code and a userInfo like the one of GrpcErrors. The details are left
+// empty, gRPC-ObjC does not decode google.rpc.Status.
+const objcErrorHelpers = `
+static NSDictionary *RNGrpcErrorInfo(NSError *error) {
+    BOOL grpc = [error.domain isEqualToString:kGRPCErrorDomain];
+    return @{
+        @"code": @(grpc ? error.code : GRPCErrorCodeUnknown),
+        @"description": error.localizedDescription ?: [NSNull null],
+        @"trailers": RNGrpcMetadataToJS(error.userInfo[kGRPCTrailersKey]),
+        @"details": @[],
+    };
+}
+
+static NSArray<NSString *> *RNGrpcCodeNames(void) {
+    return @[` + "{{codeNames}}" + `];
+}
+
+static NSString *RNGrpcCodeName(NSDictionary *info) {
+    NSArray *names = RNGrpcCodeNames();
+    NSInteger code = [info[@"code"] integerValue];
+    return code >= 0 && code < (NSInteger)names.count ? names[code] : @"UNKNOWN";
+}
+
+static void RNGrpcReject(RCTPromiseRejectBlock reject, NSError *error) {
+    NSDictionary *info = RNGrpcErrorInfo(error);
+    reject(RNGrpcCodeName(info), error.localizedDescription, [NSError errorWithDomain:error.domain code:error.code userInfo:info]);
+}
+
+// RNGrpcRejectInFlight rejects a unary call made with the requestId of one
+// still in flight, which stays cancellable under it.
+static void RNGrpcRejectInFlight(RCTPromiseRejectBlock reject, NSString *requestID) {
+    NSString *message = [NSString stringWithFormat:@"a call with requestId %@ is in flight", requestID];
+    RNGrpcReject(reject, [NSError errorWithDomain:kGRPCErrorDomain code:GRPCErrorCodeAlreadyExists userInfo:@{NSLocalizedDescriptionKey: message}]);
+}
+
+// RNGrpcRejectInvalid rejects a call javascript gave an invalid message with
+// INVALID_ARGUMENT.
+static void RNGrpcRejectInvalid(RCTPromiseRejectBlock reject, NSString *message) {
+    RNGrpcReject(reject, [NSError errorWithDomain:kGRPCErrorDomain code:GRPCErrorCodeInvalidArgument userInfo:@{NSLocalizedDescriptionKey: message}]);
+}
+
+// RNGrpcInvalidField sets error, unless the conversion of a nested message
+// already did, to the INVALID_ARGUMENT of a value of the field key that could
+// not be converted, and returns nil.
+static id RNGrpcInvalidField(NSError **error, NSString *key) {
+    if (*error == nil) {
+        NSString *message = [NSString stringWithFormat:@"invalid value for field %@", key];
+        *error = [NSError errorWithDomain:kGRPCErrorDomain code:GRPCErrorCodeInvalidArgument userInfo:@{NSLocalizedDescriptionKey: message}];
+    }
+    return nil;
+}
+
+// RNGrpcErrorFromJS returns the error javascript ends a stream with: the
+// status named by the code of event, CANCELLED by default, described by its
+// message.
+static NSError *RNGrpcErrorFromJS(NSDictionary *event) {
+    NSString *name = RNGrpcValue(event, @"code");
+    NSUInteger code = name != nil ? [RNGrpcCodeNames() indexOfObject:name] : GRPCErrorCodeCancelled;
+    if (code == NSNotFound) {
+        code = GRPCErrorCodeUnknown;
+    }
+    NSString *message = RNGrpcValue(event, @"message") ?: @"cancelled by javascript";
+    return [NSError errorWithDomain:kGRPCErrorDomain code:code userInfo:@{NSLocalizedDescriptionKey: message}];
+}
+`
+
+// swiftErrorHelpers are the objcErrorHelpers of the swift modules.
+const swiftErrorHelpers = `
+fileprivate let rnGrpcCodeNames = [` + "{{codeNames}}" + `]
+
+fileprivate func rnGrpcStatus(_ error: Error) -> GRPCStatus {
+    if let status = error as? GRPCStatusTransformable {
+        return status.makeGRPCStatus()
+    }
+    return GRPCStatus(code: .unknown, message: error.localizedDescription)
+}
+
+fileprivate func rnGrpcCodeName(_ status: GRPCStatus) -> String {
+    let code = status.code.rawValue
+    return code >= 0 && code < rnGrpcCodeNames.count ? rnGrpcCodeNames[code] : "UNKNOWN"
+}
+
+fileprivate func rnGrpcErrorInfo(_ status: GRPCStatus, trailers: HPACKHeaders?) -> [String: Any] {
+    return [
+        "code": status.code.rawValue,
+        "description": status.message ?? NSNull(),
+        "trailers": rnGrpcMetadata(trailers ?? HPACKHeaders()),
+        "details": [Any](),
+    ]
+}
+
+fileprivate func rnGrpcReject(_ reject: RCTPromiseRejectBlock, _ error: Error, trailers: HPACKHeaders?) {
+    let status = rnGrpcStatus(error)
+    let info = rnGrpcErrorInfo(status, trailers: trailers)
+    reject(rnGrpcCodeName(status), status.description, NSError(domain: "io.grpc", code: status.code.rawValue, userInfo: info))
+}
+
+// rnGrpcRejectInFlight rejects a unary call made with the requestId of one
+// still in flight, which stays cancellable under it.
+fileprivate func rnGrpcRejectInFlight(_ reject: RCTPromiseRejectBlock, _ requestID: String) {
+    rnGrpcReject(reject, GRPCStatus(code: .alreadyExists, message: "a call with requestId \(requestID) is in flight"), trailers: nil)
+}
+
+// rnGrpcRejectInvalid rejects a call javascript gave an invalid message with
+// INVALID_ARGUMENT.
+fileprivate func rnGrpcRejectInvalid(_ reject: RCTPromiseRejectBlock, _ message: String) {
+    rnGrpcReject(reject, GRPCStatus(code: .invalidArgument, message: message), trailers: nil)
+}
+
+// rnGrpcStatusFromJS returns the status javascript ends a stream with: the one
+// named by the code of event, CANCELLED by default, described by its message.
+fileprivate func rnGrpcStatusFromJS(_ event: [String: Any]?) -> GRPCStatus {
+    var code = GRPCStatus.Code.cancelled
+    if let name = event?["code"] as? String {
+        code = rnGrpcCodeNames.firstIndex(of: name).flatMap { GRPCStatus.Code(rawValue: $0) } ?? .unknown
+    }
+    return GRPCStatus(code: code, message: event?["message"] as? String ?? "cancelled by javascript")
+}
+`
+
+// statusCodes are the names of the gRPC status codes, by value.
+var statusCodes = []string{
+	"OK",
+	"CANCELLED",
+	"UNKNOWN",
+	"INVALID_ARGUMENT",
+	"DEADLINE_EXCEEDED",
+	"NOT_FOUND",
+	"ALREADY_EXISTS",
+	"PERMISSION_DENIED",
+	"RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION",
+	"ABORTED",
+	"OUT_OF_RANGE",
+	"UNIMPLEMENTED",
+	"INTERNAL",
+	"UNAVAILABLE",
+	"DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+// errorHelpers returns temp with the names of the status codes quoted by
+// prefix and the closing quote.
+func errorHelpers(temp, prefix string) string {
+	names := make([]string, len(statusCodes))
+	for i, name := range statusCodes {
+		names[i] = prefix + name + `"`
+	}
+	return strings.Replace(temp, "{{codeNames}}", strings.Join(names, ", "), 1)
+}
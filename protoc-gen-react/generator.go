@@ -24,7 +24,11 @@ import com.facebook.react.bridge.*;
 import com.facebook.react.modules.core.DeviceEventManagerModule;
 import com.google.common.util.concurrent.FutureCallback;
 import com.google.common.util.concurrent.Futures;
+import com.google.common.util.concurrent.ListenableFuture;
 import io.grpc.ManagedChannel;
+import io.grpc.StatusRuntimeException;
+import io.grpc.stub.ClientCallStreamObserver;
+import io.grpc.stub.ClientResponseObserver;
 import io.grpc.stub.StreamObserver;
 
 import com.google.protobuf.ByteString;
@@ -33,14 +37,16 @@ import java.util.ArrayList;
 import java.util.HashMap;
 import java.util.List;
 import java.util.Map;
+import java.util.concurrent.ConcurrentHashMap;
+import java.util.concurrent.Future;
 
 import {{protoPackages}}.*;
 `
 	serviceTop = `
-class {{serviceName}}Module extends ReactContextBaseJavaModule {
+public class {{serviceName}}Module extends {{baseClass}} {
     private GrpcEngine engine;
 
-    {{serviceName}}Module(ReactApplicationContext reactContext, GrpcEngine engine) {
+    public {{serviceName}}Module(ReactApplicationContext reactContext, GrpcEngine engine) {
         super(reactContext);
         this.engine = engine;
     }
@@ -55,11 +61,279 @@ class {{serviceName}}Module extends ReactContextBaseJavaModule {
     }
 
     @Override
-    public Map<String, Object> getConstants() {
+    public Map<String, Object> {{constantsMethod}}() {
         final Map<String, Object> constants = new HashMap<>();
         constants.put("NAME", {{serviceName}}Grpc.SERVICE_NAME);
         return constants;
     }
+`
+	serviceCancel = `
+    private final Map<String, Future<?>> unaryCalls = new ConcurrentHashMap<>();
+    private final Map<String, ClientCallStreamObserver<?>> streamCalls = new ConcurrentHashMap<>();
+
+    /**
+     * Cancels the call started under id. Unary calls, made with a requestId, reject with
+     * CANCELLED and streams end with a {done: true, cancelled: true} event.
+     */
+    @ReactMethod
+    public void cancel(String id) {
+        Future<?> future = unaryCalls.remove(id);
+        if (future != null) {
+            future.cancel(true);
+            return;
+        }
+        ClientCallStreamObserver<?> call = streamCalls.remove(id);
+        if (call == null) {
+            return;
+        }
+        call.cancel("cancelled by javascript", null);
+        WritableMap data = Arguments.createMap();
+        data.putBoolean("done", true);
+        data.putBoolean("cancelled", true);
+        getReactApplicationContext().getJSModule(DeviceEventManagerModule.RCTDeviceEventEmitter.class)
+                .emit(id, data);
+    }
+
+    private void forget(@Nullable String requestId, Future<?> future) {
+        if (requestId != null) {
+            unaryCalls.remove(requestId, future);
+        }
+    }
+`
+	javaNanosHelpers = `
+    private static String formatNanos(int nanos) {
+        if (nanos == 0) {
+            return "";
+        } else if (nanos % 1000000 == 0) {
+            return String.format(java.util.Locale.US, ".%03d", nanos / 1000000);
+        } else if (nanos % 1000 == 0) {
+            return String.format(java.util.Locale.US, ".%06d", nanos / 1000);
+        }
+        return String.format(java.util.Locale.US, ".%09d", nanos);
+    }
+
+    private static int parseNanos(String fraction) {
+        if (fraction == null) {
+            return 0;
+        }
+        return Integer.parseInt((fraction + "000000000").substring(0, 9));
+    }
+`
+	javaTimestampHelpers = `
+    private static final java.util.regex.Pattern TIMESTAMP = java.util.regex.Pattern.compile(
+            "(\\d{4}-\\d{2}-\\d{2}T\\d{2}:\\d{2}:\\d{2})(?:\\.(\\d{1,9}))?(Z|[+-]\\d{2}:\\d{2})");
+
+    private static java.text.SimpleDateFormat utcFormat() {
+        java.text.SimpleDateFormat format = new java.text.SimpleDateFormat("yyyy-MM-dd'T'HH:mm:ss", java.util.Locale.US);
+        format.setTimeZone(java.util.TimeZone.getTimeZone("UTC"));
+        return format;
+    }
+
+    private static String timestampToString(com.google.protobuf.Timestamp value) {
+        return utcFormat().format(new java.util.Date(value.getSeconds() * 1000)) + formatNanos(value.getNanos()) + "Z";
+    }
+
+    private static com.google.protobuf.Timestamp timestampFromString(String value) {
+        java.util.regex.Matcher m = TIMESTAMP.matcher(value);
+        if (!m.matches()) {
+            throw new IllegalArgumentException("invalid timestamp " + value);
+        }
+        long seconds;
+        try {
+            seconds = utcFormat().parse(m.group(1)).getTime() / 1000;
+        } catch (java.text.ParseException e) {
+            throw new IllegalArgumentException("invalid timestamp " + value, e);
+        }
+        String zone = m.group(3);
+        if (!zone.equals("Z")) {
+            int offset = Integer.parseInt(zone.substring(1, 3)) * 3600 + Integer.parseInt(zone.substring(4, 6)) * 60;
+            seconds -= zone.charAt(0) == '+' ? offset : -offset;
+        }
+        return com.google.protobuf.Timestamp.newBuilder().setSeconds(seconds).setNanos(parseNanos(m.group(2))).build();
+    }
+`
+	javaTimestampMillisHelpers = `
+    private static double timestampToMillis(com.google.protobuf.Timestamp value) {
+        return value.getSeconds() * 1000.0 + value.getNanos() / 1000000.0;
+    }
+
+    private static com.google.protobuf.Timestamp timestampFromMillis(double value) {
+        long seconds = (long) Math.floor(value / 1000);
+        int nanos = (int) Math.min(Math.round((value - seconds * 1000.0) * 1000000), 999999999);
+        return com.google.protobuf.Timestamp.newBuilder().setSeconds(seconds).setNanos(nanos).build();
+    }
+`
+	javaDurationHelpers = `
+    private static final java.util.regex.Pattern DURATION = java.util.regex.Pattern.compile("(-)?(\\d+)(?:\\.(\\d{1,9}))?s");
+
+    private static String durationToString(com.google.protobuf.Duration value) {
+        long seconds = value.getSeconds();
+        int nanos = value.getNanos();
+        String sign = "";
+        if (seconds < 0 || nanos < 0) {
+            sign = "-";
+            seconds = -seconds;
+            nanos = -nanos;
+        }
+        return sign + seconds + formatNanos(nanos) + "s";
+    }
+
+    private static com.google.protobuf.Duration durationFromString(String value) {
+        java.util.regex.Matcher m = DURATION.matcher(value);
+        if (!m.matches()) {
+            throw new IllegalArgumentException("invalid duration " + value);
+        }
+        long seconds = Long.parseLong(m.group(2));
+        int nanos = parseNanos(m.group(3));
+        if (m.group(1) != null) {
+            seconds = -seconds;
+            nanos = -nanos;
+        }
+        return com.google.protobuf.Duration.newBuilder().setSeconds(seconds).setNanos(nanos).build();
+    }
+`
+	javaStructHelpers = `
+    private static com.google.protobuf.Struct structFromMap(ReadableMap map) {
+        com.google.protobuf.Struct.Builder builder = com.google.protobuf.Struct.newBuilder();
+        ReadableMapKeySetIterator iter = map.keySetIterator();
+        while (iter.hasNextKey()) {
+            String key = iter.nextKey();
+            builder.putFields(key, valueFromDynamic(map.getDynamic(key)));
+        }
+        return builder.build();
+    }
+
+    private static com.google.protobuf.ListValue listValueFromArray(ReadableArray array) {
+        com.google.protobuf.ListValue.Builder builder = com.google.protobuf.ListValue.newBuilder();
+        for (int i = 0; i < array.size(); i++) {
+            builder.addValues(valueFromDynamic(array.getDynamic(i)));
+        }
+        return builder.build();
+    }
+
+    private static com.google.protobuf.Value valueFromDynamic(Dynamic value) {
+        com.google.protobuf.Value.Builder builder = com.google.protobuf.Value.newBuilder();
+        switch (value.getType()) {
+            case Boolean:
+                builder.setBoolValue(value.asBoolean());
+                break;
+            case Number:
+                builder.setNumberValue(value.asDouble());
+                break;
+            case String:
+                builder.setStringValue(value.asString());
+                break;
+            case Map:
+                builder.setStructValue(structFromMap(value.asMap()));
+                break;
+            case Array:
+                builder.setListValue(listValueFromArray(value.asArray()));
+                break;
+            default:
+                builder.setNullValue(com.google.protobuf.NullValue.NULL_VALUE);
+        }
+        value.recycle();
+        return builder.build();
+    }
+
+    private static WritableMap structToMap(com.google.protobuf.Struct struct) {
+        WritableMap map = Arguments.createMap();
+        for (Map.Entry<String, com.google.protobuf.Value> entry : struct.getFieldsMap().entrySet()) {
+            putValue(map, entry.getKey(), entry.getValue());
+        }
+        return map;
+    }
+
+    private static WritableArray listValueToArray(com.google.protobuf.ListValue list) {
+        WritableArray array = Arguments.createArray();
+        for (com.google.protobuf.Value value : list.getValuesList()) {
+            pushValue(array, value);
+        }
+        return array;
+    }
+
+    private static void putValue(WritableMap map, String key, com.google.protobuf.Value value) {
+        switch (value.getKindCase()) {
+            case BOOL_VALUE:
+                map.putBoolean(key, value.getBoolValue());
+                break;
+            case NUMBER_VALUE:
+                map.putDouble(key, value.getNumberValue());
+                break;
+            case STRING_VALUE:
+                map.putString(key, value.getStringValue());
+                break;
+            case STRUCT_VALUE:
+                map.putMap(key, structToMap(value.getStructValue()));
+                break;
+            case LIST_VALUE:
+                map.putArray(key, listValueToArray(value.getListValue()));
+                break;
+            default:
+                map.putNull(key);
+        }
+    }
+
+    private static void pushValue(WritableArray array, com.google.protobuf.Value value) {
+        switch (value.getKindCase()) {
+            case BOOL_VALUE:
+                array.pushBoolean(value.getBoolValue());
+                break;
+            case NUMBER_VALUE:
+                array.pushDouble(value.getNumberValue());
+                break;
+            case STRING_VALUE:
+                array.pushString(value.getStringValue());
+                break;
+            case STRUCT_VALUE:
+                array.pushMap(structToMap(value.getStructValue()));
+                break;
+            case LIST_VALUE:
+                array.pushArray(listValueToArray(value.getListValue()));
+                break;
+            default:
+                array.pushNull();
+        }
+    }
+`
+	javaFieldMaskHelpers = `
+    private static com.google.protobuf.FieldMask fieldMaskFromString(String value) {
+        com.google.protobuf.FieldMask.Builder builder = com.google.protobuf.FieldMask.newBuilder();
+        for (String path : value.split(",")) {
+            if (path.isEmpty()) {
+                continue;
+            }
+            StringBuilder snake = new StringBuilder();
+            for (char c : path.toCharArray()) {
+                if (Character.isUpperCase(c)) {
+                    snake.append('_').append(Character.toLowerCase(c));
+                } else {
+                    snake.append(c);
+                }
+            }
+            builder.addPaths(snake.toString());
+        }
+        return builder.build();
+    }
+
+    private static String fieldMaskToString(com.google.protobuf.FieldMask value) {
+        StringBuilder out = new StringBuilder();
+        for (String path : value.getPathsList()) {
+            if (out.length() > 0) {
+                out.append(',');
+            }
+            boolean upper = false;
+            for (char c : path.toCharArray()) {
+                if (c == '_') {
+                    upper = true;
+                } else {
+                    out.append(upper ? Character.toUpperCase(c) : c);
+                    upper = false;
+                }
+            }
+        }
+        return out.toString();
+    }
 `
 )
 
@@ -97,15 +371,77 @@ func ToFileName(pre string) string {
 	return strings.Title(pre)
 }
 
+// Options configures the code emitted by the generator.
+type Options struct {
+	// PackageName overrides the java_package option of the generated modules.
+	PackageName string
+	// Target is the native platform the modules are generated for:
+	// "android", "objc" or "swift".
+	Target string
+	// Lang is the language of the android modules: "java" or "kotlin". Both
+	// convert messages with the same java converters classes.
+	Lang string
+	// TurboModule makes the android modules, GrpcConfig included, implement
+	// the Native<Module>Spec classes generated by the React Native codegen
+	// from SpecPackage, and GeneratedGrpcPackage register them as turbo
+	// modules.
+	TurboModule bool
+	SpecPackage string
+	// Int64 is how 64-bit integers cross the bridge: "string", which is
+	// lossless, or "number".
+	Int64 string
+	// Timestamp is how google.protobuf.Timestamp crosses the bridge: "iso",
+	// an RFC 3339 string, or "ms", milliseconds since the epoch.
+	Timestamp string
+	// Bytes is how bytes fields cross the bridge: "base64", as in proto3
+	// JSON, or "utf8", their text for payloads known to be strings.
+	Bytes string
+	// Runtime is the protobuf runtime the android modules are compiled
+	// against: "full" or "lite", protobuf-javalite.
+	Runtime string
+	// EnginePackage is the java package of the GrpcEngine, its support
+	// classes and the GeneratedGrpcPackage registering every module. By
+	// default it is the package of the first module.
+	EnginePackage string
+}
+
 type generator struct {
-	reg         *descriptor.Registry
-	mapValues   []string
-	packageName string
+	reg       *descriptor.Registry
+	mapValues []string
+	// helpers are the support functions used by the code being generated.
+	helpers []helper
+	// converters is the converters class of each message by full name,
+	// convertersClass the one being generated.
+	converters      map[string]*javaConverters
+	convertersClass *javaConverters
+	// javaImports are the java packages imported by the file being
+	// generated, classes of other packages are fully qualified.
+	javaImports map[string]bool
+	// enginePackage is the java package the engine classes are generated in.
+	enginePackage string
+	Options
 }
 
 // New returns a new generator which generates grpc gateway files.
-func NewGenerator(reg *descriptor.Registry, packageName string) gen.Generator {
-	return &generator{reg: reg, mapValues: []string{}, packageName: packageName}
+func NewGenerator(reg *descriptor.Registry, opts Options) gen.Generator {
+	return &generator{reg: reg, mapValues: []string{}, Options: opts}
+}
+
+// moduleBaseClass returns the class the android module of svc extends.
+func (g *generator) moduleBaseClass(svc *descriptor.Service) string {
+	if g.TurboModule {
+		return "Native" + svc.GetName() + "Spec"
+	}
+	return "ReactContextBaseJavaModule"
+}
+
+// constantsMethod returns the method exporting the constants of a module,
+// codegen specs make getConstants final.
+func (g *generator) constantsMethod() string {
+	if g.TurboModule {
+		return "getTypedExportedConstants"
+	}
+	return "getConstants"
 }
 
 func (g *generator) getJavaType(f *gdescriptor.FieldDescriptorProto, file *descriptor.File) string {
@@ -116,35 +452,147 @@ func (g *generator) getJavaType(f *gdescriptor.FieldDescriptorProto, file *descr
 		return "String"
 	case gdescriptor.FieldDescriptorProto_TYPE_INT32,
 		gdescriptor.FieldDescriptorProto_TYPE_SFIXED32,
-		gdescriptor.FieldDescriptorProto_TYPE_SINT32:
-		return "long"
+		gdescriptor.FieldDescriptorProto_TYPE_SINT32,
+		gdescriptor.FieldDescriptorProto_TYPE_UINT32,
+		gdescriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "int"
 	case gdescriptor.FieldDescriptorProto_TYPE_INT64,
 		gdescriptor.FieldDescriptorProto_TYPE_SFIXED64,
-		gdescriptor.FieldDescriptorProto_TYPE_SINT64:
-		return "int"
+		gdescriptor.FieldDescriptorProto_TYPE_SINT64,
+		gdescriptor.FieldDescriptorProto_TYPE_UINT64,
+		gdescriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "long"
 	case gdescriptor.FieldDescriptorProto_TYPE_FLOAT:
 		return "float"
 	case gdescriptor.FieldDescriptorProto_TYPE_DOUBLE:
 		return "double"
+	case gdescriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "ByteString"
 	case gdescriptor.FieldDescriptorProto_TYPE_MESSAGE:
-		m, _ := g.reg.LookupMsg(file.GetPackage(), f.GetTypeName())
-		pkg := m.File.GetPackage()
-		tname := f.GetTypeName()
-		if strings.HasPrefix(tname, ".") {
-			tname = strings.Replace(tname, "." + pkg + ".", "", -1)
-		} else {
-			tname = strings.Replace(tname, pkg + ".", "", -1)
+		m, err := g.reg.LookupMsg(file.GetPackage(), f.GetTypeName())
+		if err != nil {
+			return ""
 		}
-		return tname
-
+		return g.javaMessageName(m)
 	case gdescriptor.FieldDescriptorProto_TYPE_ENUM:
-		m, _ := g.reg.LookupMsg(file.GetPackage(), f.GetTypeName())
-		return m.GetName()
+		e, err := g.reg.LookupEnum(file.GetPackage(), f.GetTypeName())
+		if err != nil {
+			return ""
+		}
+		return g.javaClassName(e.File, e.Outers, e.GetName())
 	}
 	return ""
 }
 
-func getReactMapType(f *gdescriptor.FieldDescriptorProto, fromProto bool) (string, string, string) {
+// javaMessageName returns the name of the Java class of m, e.g. Outer.Inner
+// for nested messages.
+func (g *generator) javaMessageName(m *descriptor.Message) string {
+	return g.javaClassName(m.File, m.Outers, m.GetName())
+}
+
+// javaClassName returns the name of the Java class generated by protoc for
+// the message or enum name declared in file, relative to its package when the
+// code being generated imports it and fully qualified otherwise.
+func (g *generator) javaClassName(file *descriptor.File, outers []string, name string) string {
+	name = strings.Join(append(append([]string{}, outers...), name), ".")
+	if file.GetPackage() == "google.protobuf" {
+		return "com.google.protobuf." + name
+	}
+	if !file.Options.GetJavaMultipleFiles() {
+		name = javaOuterClassName(file) + "." + name
+	}
+	if pkg := javaPackageOf(file); !g.javaImports[pkg] {
+		return pkg + "." + name
+	}
+	return name
+}
+
+// javaOuterClassName returns the class protoc wraps the types of file in
+// unless java_multiple_files is set: java_outer_classname, or the camel cased
+// file name suffixed with OuterClass when a type of the file has that name.
+func javaOuterClassName(file *descriptor.File) string {
+	if name := file.Options.GetJavaOuterClassname(); name != "" {
+		return name
+	}
+	base := strings.TrimSuffix(filepath.Base(file.GetName()), ".proto")
+	name := ""
+	upper := true
+	for _, r := range base {
+		switch {
+		case r >= 'a' && r <= 'z':
+			if upper {
+				r -= 'a' - 'A'
+			}
+			name += string(r)
+			upper = false
+		case r >= 'A' && r <= 'Z':
+			name += string(r)
+			upper = false
+		case r >= '0' && r <= '9':
+			name += string(r)
+			upper = true
+		default:
+			upper = true
+		}
+	}
+	for _, m := range file.Messages {
+		if m.GetName() == name {
+			return name + "OuterClass"
+		}
+	}
+	for _, e := range file.Enums {
+		if e.GetName() == name {
+			return name + "OuterClass"
+		}
+	}
+	for _, svc := range file.GetService() {
+		if svc.GetName() == name {
+			return name + "OuterClass"
+		}
+	}
+	return name
+}
+
+// javaPackageOf returns the java package of the classes protoc generates for
+// file, its proto package unless java_package is set.
+func javaPackageOf(file *descriptor.File) string {
+	if pkg := file.Options.GetJavaPackage(); pkg != "" {
+		return pkg
+	}
+	return file.GetPackage()
+}
+
+// flatMessageName returns the name of m prefixed with the messages it is
+// nested in, e.g. OuterInner.
+func (g *generator) flatMessageName(m *descriptor.Message) string {
+	return strings.Join(append(append([]string{}, m.Outers...), m.GetName()), "")
+}
+
+// javaFromMapName returns the name of the method building m from a
+// ReadableMap, the other way is an overload of toWritableMap.
+func (g *generator) javaFromMapName(m *descriptor.Message) string {
+	name := g.flatMessageName(m)
+	return strings.ToLower(name[:1]) + name[1:] + "FromReadableMap"
+}
+
+// javaBoxedType returns the type used for t in generic collections.
+func javaBoxedType(t string) string {
+	switch t {
+	case "boolean":
+		return "Boolean"
+	case "int":
+		return "Integer"
+	case "long":
+		return "Long"
+	case "float":
+		return "Float"
+	case "double":
+		return "Double"
+	}
+	return t
+}
+
+func (g *generator) getReactMapType(f *gdescriptor.FieldDescriptorProto, fromProto bool) (string, string, string) {
 	switch f.GetType() {
 	case gdescriptor.FieldDescriptorProto_TYPE_BOOL:
 		return "Boolean", "", ""
@@ -154,35 +602,141 @@ func getReactMapType(f *gdescriptor.FieldDescriptorProto, fromProto bool) (strin
 		gdescriptor.FieldDescriptorProto_TYPE_SFIXED32,
 		gdescriptor.FieldDescriptorProto_TYPE_SINT32:
 		return "Int", "", ""
+	case gdescriptor.FieldDescriptorProto_TYPE_UINT32,
+		gdescriptor.FieldDescriptorProto_TYPE_FIXED32:
+		// Java keeps the unsigned value in the bits of an int.
+		if fromProto {
+			return "Double", "Integer.toUnsignedLong(", ")"
+		}
+		return "Double", "(int) (long) ", ""
 	case gdescriptor.FieldDescriptorProto_TYPE_INT64,
 		gdescriptor.FieldDescriptorProto_TYPE_SFIXED64,
 		gdescriptor.FieldDescriptorProto_TYPE_SINT64:
+		if g.Int64 == "number" {
+			if fromProto {
+				return "Double", "(double) ", ""
+			}
+			return "Double", "(long) ", ""
+		}
 		if fromProto {
 			return "String", "Long.toString(", ")"
 		} else {
-			return "Int", "", ""
+			return "String", "Long.parseLong(", ")"
+		}
+	case gdescriptor.FieldDescriptorProto_TYPE_UINT64,
+		gdescriptor.FieldDescriptorProto_TYPE_FIXED64:
+		if g.Int64 == "number" {
+			if fromProto {
+				return "Double", "Double.parseDouble(Long.toUnsignedString(", "))"
+			}
+			return "Double", "new java.math.BigDecimal(", ").longValue()"
+		}
+		if fromProto {
+			return "String", "Long.toUnsignedString(", ")"
+		} else {
+			return "String", "Long.parseUnsignedLong(", ")"
 		}
 	case gdescriptor.FieldDescriptorProto_TYPE_FLOAT:
 		return "Double", "(float)", ""
 	case gdescriptor.FieldDescriptorProto_TYPE_DOUBLE:
 		return "Double", "", ""
 	case gdescriptor.FieldDescriptorProto_TYPE_MESSAGE:
-		return "Message", "", ""
+		if mapType, prefix, suffix, ok := g.javaWellKnownType(f.GetTypeName(), fromProto); ok {
+			return mapType, prefix, suffix
+		}
+		m, err := g.reg.LookupMsg("", f.GetTypeName())
+		if err != nil {
+			return "", "", ""
+		}
+		if fromProto {
+			return "Map", g.javaToMap(m) + "(", ")"
+		}
+		return "Map", g.javaFromMap(m) + "(", ")"
 	case gdescriptor.FieldDescriptorProto_TYPE_ENUM:
+		// Enums are set by number, see the Value suffixed builder methods.
 		if fromProto {
 			return "Int", "", ".getNumber()"
 		} else {
-			return "Enum", "", ""
+			return "Int", "", ""
 		}
 	case gdescriptor.FieldDescriptorProto_TYPE_BYTES:
+		if g.Bytes == "utf8" {
+			if fromProto {
+				return "String", "", ".toStringUtf8()"
+			}
+			return "String", "ByteString.copyFromUtf8(", ")"
+		}
 		if fromProto {
-			return "String", "", ".toStringUtf8()"
-		} else {
-			return "Bytes", "", ""
+			return "String", "android.util.Base64.encodeToString(", ".toByteArray(), android.util.Base64.NO_WRAP)"
 		}
+		return "String", "ByteString.copyFrom(android.util.Base64.decode(", ", android.util.Base64.DEFAULT))"
 	}
 	return "", "", ""
 }
+
+// javaWellKnownType returns the conversion of the well-known type typeName,
+// in the form returned by getReactMapType.
+func (g *generator) javaWellKnownType(typeName string, fromProto bool) (string, string, string, bool) {
+	switch {
+	case typeName == wktTimestamp && g.Timestamp == "ms":
+		g.useHelper("timestampMillis", javaTimestampMillisHelpers)
+		if fromProto {
+			return "Double", "timestampToMillis(", ")", true
+		}
+		return "Double", "timestampFromMillis(", ")", true
+	case typeName == wktTimestamp:
+		g.useHelper("nanos", javaNanosHelpers)
+		g.useHelper("timestamp", javaTimestampHelpers)
+		if fromProto {
+			return "String", "timestampToString(", ")", true
+		}
+		return "String", "timestampFromString(", ")", true
+	case typeName == wktDuration:
+		g.useHelper("nanos", javaNanosHelpers)
+		g.useHelper("duration", javaDurationHelpers)
+		if fromProto {
+			return "String", "durationToString(", ")", true
+		}
+		return "String", "durationFromString(", ")", true
+	case typeName == wktStruct:
+		g.useHelper("struct", javaStructHelpers)
+		if fromProto {
+			return "Map", "structToMap(", ")", true
+		}
+		return "Map", "structFromMap(", ")", true
+	case typeName == wktListValue:
+		g.useHelper("struct", javaStructHelpers)
+		if fromProto {
+			return "Array", "listValueToArray(", ")", true
+		}
+		return "Array", "listValueFromArray(", ")", true
+	case typeName == wktAny:
+		if fromProto {
+			return "Map", "anyToMap(", ")", true
+		}
+		return "Map", "anyFromMap(", ")", true
+	case typeName == wktValue:
+		// WritableMap and WritableArray have no setter taking any value,
+		// the writers call putValue and pushValue instead.
+		g.useHelper("struct", javaStructHelpers)
+		if fromProto {
+			return "Value", "", "", true
+		}
+		return "Dynamic", "valueFromDynamic(", ")", true
+	default:
+		valueField, err := g.wrapperValueField(typeName)
+		if err != nil {
+			return "", "", "", false
+		}
+		mapType, prefix, suffix := g.getReactMapType(valueField, fromProto)
+		if fromProto {
+			return mapType, prefix, ".getValue()" + suffix, true
+		}
+		javaType := "com.google.protobuf." + wellKnownName(typeName)
+		return mapType, javaType + ".newBuilder().setValue(" + prefix, suffix + ").build()", true
+	}
+}
+
 func (g *generator) isMap(field *descriptor.Field, file *descriptor.File) bool {
 	m, err := g.reg.LookupMsg(file.GetPackage(), field.GetTypeName())
 	if err != nil {
@@ -191,186 +745,160 @@ func (g *generator) isMap(field *descriptor.Field, file *descriptor.File) bool {
 	return m.GetOptions().GetMapEntry()
 }
 
-func (g *generator) arrayToBuilder(f *descriptor.Field, file *descriptor.File, mapName string, builderName string, buf io.Writer) error {
-	mapType, prefix, suffix := getReactMapType(f.FieldDescriptorProto, false)
-	if mapType == "Message" {
-		tempStart := `		if ({{mapName}}.hasKey("{{jsonName}}")) {
-			ReadableArray array_{{jsonName}} = {{mapName}}.getArray("{{jsonName}}");
-			List<{{javaType}}> list_{{jsonName}} = new ArrayList<>();
-			for(int i_{{jsonName}} = 0; i_{{jsonName}} < array_{{jsonName}}.size(); i_{{jsonName}}++){
-				{{javaType}}.Builder {{jsonName}}_builder = {{javaType}}.newBuilder();
-				ReadableMap {{jsonName}}_map = array_{{jsonName}}.getMap(i_{{jsonName}});
-`
-		tempEnd := `	list_{{jsonName}}.add({{jsonName}}_builder.build());
-			}
-            {{builderName}}.addAll{{javaName}}(list_{{jsonName}});
-        }`
-		m, err := g.reg.LookupMsg(file.GetPackage(), f.GetTypeName())
-		if err != nil {
-			return err
+func (g *generator) isMapField(f *gdescriptor.FieldDescriptorProto) bool {
+	if f.GetType() != gdescriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	m, err := g.reg.LookupMsg("", f.GetTypeName())
+	if err != nil {
+		return false
+	}
+	return m.GetOptions().GetMapEntry()
+}
+
+func (g *generator) mapValueField(f *gdescriptor.FieldDescriptorProto) (*gdescriptor.FieldDescriptorProto, error) {
+	entry, err := g.reg.LookupMsg("", f.GetTypeName())
+	if err != nil {
+		return nil, err
+	}
+	for _, ff := range entry.GetField() {
+		if ff.GetName() == "value" {
+			return ff, nil
 		}
-		javaType := g.getJavaType(f.FieldDescriptorProto, file)
-		fasttemplate.Execute(tempStart, "{{", "}}", buf, map[string]interface{}{
-			"jsonName":    f.GetJsonName(),
-			"javaName":    strings.Title(f.GetJsonName()),
-			"mapName":     mapName,
-			"javaType":    javaType,
-			"javaMapType": mapType,
-			"builderName": builderName,
-		})
+	}
+	return nil, fmt.Errorf("map entry %s has no value field", entry.GetName())
+}
 
-		g.readableMapToBuilder(m, file, fmt.Sprintf("%s_map", f.GetJsonName()), fmt.Sprintf("%s_builder", f.GetJsonName()), buf)
-		fasttemplate.Execute(tempEnd, "{{", "}}", buf, map[string]interface{}{
-			"javaName":    strings.Title(f.GetJsonName()),
-			"builderName": builderName,
-			"jsonName":    f.GetJsonName(),
-		})
-	} else if mapType == "Bytes" {
-		temp := `		if ({{mapName}}.hasKey("{{jsonName}}")) {
-			ReadableArray array_{{jsonName}} = {{mapName}}.getArray("{{jsonName}}");
-			List<ByteString> list_{{jsonName}} = new ArrayList<>();
-			for(int i_{{jsonName}} = 0; i_{{jsonName}} < array_{{jsonName}}.size(); i_{{jsonName}}++){
-				list_{{jsonName}}.add(ByteString.copyFromUtf8(array_{{jsonName}}.getString(i_{{jsonName}})));
+// reachableMessages returns every message used by the methods of the file's
+// services, directly or through fields, in the order they are first seen.
+// Map entries are walked but not returned, well-known JSON types are skipped.
+// When one of them holds a google.protobuf.Any, every message declared in the
+// file is returned too, as they make up the registry Any is resolved against.
+func (g *generator) reachableMessages(file *descriptor.File) []*descriptor.Message {
+	out, _ := g.walkMessages(file)
+	return out
+}
+
+// usesAny reports whether the messages used by the file's services hold a
+// google.protobuf.Any.
+func (g *generator) usesAny(file *descriptor.File) bool {
+	_, usesAny := g.walkMessages(file)
+	return usesAny
+}
+
+func (g *generator) walkMessages(file *descriptor.File) ([]*descriptor.Message, bool) {
+	var roots []*descriptor.Message
+	for _, svc := range file.Services {
+		for _, m := range svc.Methods {
+			roots = append(roots, m.RequestType, m.ResponseType)
+		}
+	}
+	out, usesAny := g.collectMessages(roots)
+	if usesAny {
+		out, _ = g.collectMessages(append(roots, file.Messages...))
+	}
+	return out, usesAny
+}
+
+// collectMessages returns roots and the messages their fields use, in the
+// order they are first seen, and whether one of them holds a
+// google.protobuf.Any. Map entries are walked but not returned, well-known
+// JSON types are skipped.
+func (g *generator) collectMessages(roots []*descriptor.Message) ([]*descriptor.Message, bool) {
+	var out []*descriptor.Message
+	usesAny := false
+	seen := map[string]bool{}
+	var visit func(m *descriptor.Message)
+	visit = func(m *descriptor.Message) {
+		if seen[m.FQMN()] {
+			return
+		}
+		seen[m.FQMN()] = true
+		if !m.GetOptions().GetMapEntry() {
+			out = append(out, m)
+		}
+		for _, f := range m.Fields {
+			if f.GetTypeName() == wktAny {
+				usesAny = true
 			}
-            {{builderName}}.addAll{{javaName}}(list_{{jsonName}});
-        }
-`
-		fasttemplate.Execute(temp, "{{", "}}", buf, map[string]interface{}{
-			"jsonName":    f.GetJsonName(),
-			"javaName":    strings.Title(f.GetJsonName()),
-			"mapName":     mapName,
-			"javaType":    g.getJavaType(f.FieldDescriptorProto, file),
-			"javaMapType": mapType,
-			"builderName": builderName,
-		})
-	} else {
-		temp := `		if ({{mapName}}.hasKey("{{jsonName}}")) {
+			if f.GetType() != gdescriptor.FieldDescriptorProto_TYPE_MESSAGE || isWellKnownJSON(f.GetTypeName()) {
+				continue
+			}
+			if fm, err := g.reg.LookupMsg(m.File.GetPackage(), f.GetTypeName()); err == nil {
+				visit(fm)
+			}
+		}
+	}
+	for _, m := range roots {
+		visit(m)
+	}
+	return out, usesAny
+}
+
+func (g *generator) arrayToBuilder(f *descriptor.Field, file *descriptor.File, mapName string, builderName string, buf io.Writer) error {
+	mapType, prefix, suffix := g.getReactMapType(f.FieldDescriptorProto, false)
+	javaName := strings.Title(f.GetJsonName())
+	boxedType := javaBoxedType(g.getJavaType(f.FieldDescriptorProto, file))
+	if f.GetType() == gdescriptor.FieldDescriptorProto_TYPE_ENUM {
+		javaName += "Value"
+		boxedType = "Integer"
+	}
+	temp := `		if ({{mapName}}.hasKey("{{jsonName}}")) {
 			ReadableArray array_{{jsonName}} = {{mapName}}.getArray("{{jsonName}}");
-			List<{{javaType}}> list_{{jsonName}} = new ArrayList<>();
+			List<{{boxedType}}> list_{{jsonName}} = new ArrayList<>();
 			for(int i_{{jsonName}} = 0; i_{{jsonName}} < array_{{jsonName}}.size(); i_{{jsonName}}++){
-				list_{{jsonName}}.add(array_{{jsonName}}.get{{javaMapType}}(i_{{jsonName}}));
+				list_{{jsonName}}.add({{prefix}}array_{{jsonName}}.get{{javaMapType}}(i_{{jsonName}}){{suffix}});
 			}
             {{builderName}}.addAll{{javaName}}(list_{{jsonName}});
         }
 `
-		fasttemplate.Execute(temp, "{{", "}}", buf, map[string]interface{}{
-			"jsonName":    f.GetJsonName(),
-			"javaName":    strings.Title(f.GetJsonName()),
-			"mapName":     mapName,
-			"javaType":    g.getJavaType(f.FieldDescriptorProto, file),
-			"javaMapType": mapType,
-			"builderName": builderName,
-			"prefix":      prefix,
-			"suffix":      suffix,
-		})
-	}
-	return nil
+	_, err := fasttemplate.Execute(temp, "{{", "}}", buf, map[string]interface{}{
+		"jsonName":    f.GetJsonName(),
+		"javaName":    javaName,
+		"mapName":     mapName,
+		"boxedType":   boxedType,
+		"javaMapType": mapType,
+		"builderName": builderName,
+		"prefix":      prefix,
+		"suffix":      suffix,
+	})
+	return err
 }
 func (g *generator) mapToBuilder(f *descriptor.Field, file *descriptor.File, mapName string, builderName string, buf io.Writer) error {
-	mapEntry, err := g.reg.LookupMsg(file.GetPackage(), f.FieldDescriptorProto.GetTypeName())
+	valueField, err := g.mapValueField(f.FieldDescriptorProto)
 	if err != nil {
 		return err
 	}
-	var valueField *gdescriptor.FieldDescriptorProto
-	for _, ff := range mapEntry.GetField() {
-		if ff.GetName() == "value" {
-			valueField = ff
-			break
-		}
+	mapType, prefix, suffix := g.getReactMapType(valueField, false)
+	javaName := strings.Title(f.GetJsonName())
+	if valueField.GetType() == gdescriptor.FieldDescriptorProto_TYPE_ENUM {
+		javaName += "Value"
 	}
-	mapType, _, _ := getReactMapType(valueField, false)
-	if mapType == "Bytes" {
-		temp := `ReadableMap map_{{jsonName}} = {{mapName}}.getMap("{{jsonName}}");
+	temp := `ReadableMap map_{{jsonName}} = {{mapName}}.getMap("{{jsonName}}");
 		ReadableMapKeySetIterator iter_{{jsonName}}= map_{{jsonName}}.keySetIterator();
         while(iter_{{jsonName}}.hasNextKey()){
 			String key_{{jsonName}}=iter_{{jsonName}}.nextKey();
-			String value_{{jsonName}} = map_{{jsonName}}.getString(key_{{jsonName}});
-            {{builderName}}.put{{javaName}}(key_{{jsonName}}, ByteString.copyFromUtf8(value_{{jsonName}}));
+            {{builderName}}.put{{javaName}}(key_{{jsonName}}, {{prefix}}map_{{jsonName}}.get{{mapType}}(key_{{jsonName}}){{suffix}});
         }`
-		_, err := fasttemplate.Execute(temp, "{{", "}}", buf, map[string]interface{}{
-			"jsonName":    f.GetJsonName(),
-			"javaName":    strings.Title(f.GetJsonName()),
-			"mapName":     mapName,
-			"builderName": builderName,
-			"mapType":     mapType,
-		})
-		return err
-	} else if mapType == "Enum" {
-		temp := ` ReadableMapKeySetIterator iter_{{jsonName}}={{mapName}}.getMap("{{jsonName}}").keySetIterator();
-        while(iter_{{jsonName}}.hasNextKey()){
-			String key_{{jsonName}}=iter_{{jsonName}}.nextKey();
-            {{builderName}}.put{{javaName}}(key_{{jsonName}}, {{mapName}}.getMap("{{jsonName}}").getInt(key_{{jsonName}}));
-        }`
-		_, err := fasttemplate.Execute(temp, "{{", "}}", buf, map[string]interface{}{
-			"jsonName":    f.GetJsonName(),
-			"javaName":    strings.Title(f.GetJsonName()),
-			"mapName":     mapName,
-			"builderName": builderName,
-			"mapType":     mapType,
-		})
-		return err
-	} else if mapType == "Message" {
-		m, err := g.reg.LookupMsg(file.GetPackage(), valueField.GetName())
-		if err != nil {
-			return err
-		}
-		javaType := g.getJavaType(valueField, file)
-		tempStart := `ReadableMap map_{{jsonName}} = {{mapName}}.getMap("{{jsonName}}");
-		ReadableMapKeySetIterator iter_{{jsonName}}= map_{{jsonName}}.keySetIterator();
-        while(iter_{{jsonName}}.hasNextKey()){
-            {{javaType}}.Builder builder_{{jsonName}} = {{javaType}}.newBuilder();
-			String key_{{jsonName}}=iter_{{jsonName}}.nextKey();
-			ReadableMap map_{{jsonName}}_inner = map_{{jsonName}}.getMap(key_{{jsonName}});
-        `
-		tempEnd := `{{builderName}}.put{{javaName}}(key_{{jsonName}}, builder_{{jsonName}}.build());
-		}`
 
-		if _, err := fasttemplate.Execute(tempStart, "{{", "}}", buf, map[string]interface{}{
-			"jsonName":    f.GetJsonName(),
-			"javaName":    strings.Title(f.GetJsonName()),
-			"mapName":     mapName,
-			"builderName": builderName,
-			"mapType":     mapType,
-			"javaType":    javaType,
-		}); err != nil {
-			return err
-		}
-		g.readableMapToBuilder(m, file, fmt.Sprintf("map_%s_inner", f.GetJsonName()), fmt.Sprintf("builder_%s", f.GetJsonName()), buf)
-
-		if _, err := fasttemplate.Execute(tempEnd, "{{", "}}", buf, map[string]interface{}{
-			"jsonName":    f.GetJsonName(),
-			"javaName":    strings.Title(f.GetJsonName()),
-			"mapName":     mapName,
-			"builderName": builderName,
-			"mapType":     mapType,
-			"javaType":    javaType,
-		}); err != nil {
-			return err
-		}
-
-	} else {
-		temp := `ReadableMap map_{{jsonName}} = {{mapName}}.getMap("{{jsonName}}");
-		ReadableMapKeySetIterator iter_{{jsonName}}= map_{{jsonName}}.keySetIterator();
-        while(iter_{{jsonName}}.hasNextKey()){
-			String key_{{jsonName}}=iter_{{jsonName}}.nextKey();
-            {{builderName}}.put{{javaName}}(key_{{jsonName}}, map_{{jsonName}}.get{{mapType}}(key_{{jsonName}}));
-        }`
-
-		_, err := fasttemplate.Execute(temp, "{{", "}}", buf, map[string]interface{}{
-			"jsonName":    f.GetJsonName(),
-			"javaName":    strings.Title(f.GetJsonName()),
-			"mapName":     mapName,
-			"builderName": builderName,
-			"mapType":     mapType,
-		})
-		return err
-	}
-	return nil
+	_, err = fasttemplate.Execute(temp, "{{", "}}", buf, map[string]interface{}{
+		"jsonName":    f.GetJsonName(),
+		"javaName":    javaName,
+		"mapName":     mapName,
+		"builderName": builderName,
+		"mapType":     mapType,
+		"prefix":      prefix,
+		"suffix":      suffix,
+	})
+	return err
 }
 func (g *generator) readableMapToBuilder(mes *descriptor.Message, file *descriptor.File, mapName string, builderName string, buf io.Writer) error {
 	for _, f := range mes.Fields {
-		javaName := f.GetJsonName()
-		mapType, prefix, suffix := getReactMapType(f.FieldDescriptorProto, false)
+		javaName := strings.Title(f.GetJsonName())
+		if f.GetType() == gdescriptor.FieldDescriptorProto_TYPE_ENUM {
+			javaName += "Value"
+		}
+		mapType, prefix, suffix := g.getReactMapType(f.FieldDescriptorProto, false)
 		isArray := f.GetLabel() == gdescriptor.FieldDescriptorProto_LABEL_REPEATED
 
 		if g.isMap(f, file) {
@@ -388,68 +916,14 @@ func (g *generator) readableMapToBuilder(mes *descriptor.Message, file *descript
 			if err := g.arrayToBuilder(f, file, mapName, builderName, buf); err != nil {
 				return err
 			}
-		} else if mapType == "Message" {
-			javaType := g.getJavaType(f.FieldDescriptorProto, file)
-			temp := `		if ({{mapName}}.hasKey("{{jsonName}}")) {
-				{{javaType}}.Builder builder_{{jsonName}} = {{javaType}}.newBuilder();
-				ReadableMap in_{{jsonName}} = {{mapName}}.getMap("{{jsonName}}");
-`
-			fasttemplate.Execute(temp, "{{", "}}", buf, map[string]interface{}{
-				"jsonName":    f.GetJsonName(),
-				"javaName":    strings.Title(javaName),
-				"mapType":     mapType,
-				"mapName":     mapName,
-				"builderName": builderName,
-				"javaType":    javaType,
-			})
-			mes, _ := g.reg.LookupMsg(file.GetPackage(), f.GetTypeName())
-			g.readableMapToBuilder(mes, file,
-				fmt.Sprintf("in_%s", f.GetJsonName()),
-				fmt.Sprintf("builder_%s", f.GetJsonName()), buf)
-
-			tempEnd := `{{builderName}}.set{{javaName}}(builder_{{jsonName}});
-				}`
-			fasttemplate.Execute(tempEnd, "{{", "}}", buf, map[string]interface{}{
-				"jsonName":    f.GetJsonName(),
-				"javaName":    strings.Title(javaName),
-				"mapType":     mapType,
-				"mapName":     mapName,
-				"builderName": builderName,
-				"javaType":    javaType,
-			})
-		} else if mapType == "Enum" {
-			temp := `		if ({{mapName}}.hasKey("{{jsonName}}")) {
-            {{builderName}}.set{{javaName}}Value({{mapName}}.getInt("{{jsonName}}"));
-        }
-`
-			fasttemplate.Execute(temp, "{{", "}}", buf, map[string]interface{}{
-				"jsonName":    f.GetJsonName(),
-				"javaName":    strings.Title(javaName),
-				"mapName":     mapName,
-				"builderName": builderName,
-			})
-		} else if mapType == "Bytes" {
-			temp := `		if ({{mapName}}.hasKey("{{jsonName}}")) {
-            {{builderName}}.set{{javaName}}(ByteString.copyFromUtf8({{mapName}}.getString("{{jsonName}}")));
-        }
-`
-			fasttemplate.Execute(temp, "{{", "}}", buf, map[string]interface{}{
-				"jsonName":    f.GetJsonName(),
-				"javaName":    strings.Title(javaName),
-				"mapType":     mapType,
-				"mapName":     mapName,
-				"builderName": builderName,
-				"prefix":      prefix,
-				"suffix":      suffix,
-			})
 		} else {
-			temp := `		if ({{mapName}}.hasKey("{{jsonName}}")) {
+			temp := `		if ({{mapName}}.hasKey("{{jsonName}}") && !{{mapName}}.isNull("{{jsonName}}")) {
             {{builderName}}.set{{javaName}}({{prefix}}{{mapName}}.get{{mapType}}("{{jsonName}}"){{suffix}});
         }
 `
 			fasttemplate.Execute(temp, "{{", "}}", buf, map[string]interface{}{
 				"jsonName":    f.GetJsonName(),
-				"javaName":    strings.Title(javaName),
+				"javaName":    javaName,
 				"mapType":     mapType,
 				"mapName":     mapName,
 				"builderName": builderName,
@@ -464,20 +938,13 @@ func (g *generator) readableMapToBuilder(mes *descriptor.Message, file *descript
 func (g *generator) protoMapToMap(f *descriptor.Field, file *descriptor.File, mapName string, messageName string, buf io.Writer) error {
 	innerMap := fmt.Sprintf("%s_%s", mapName, f.GetJsonName())
 	javaMap := fmt.Sprintf("%s_%s", messageName, f.GetJsonName())
-	mapEntry, err := g.reg.LookupMsg(file.GetPackage(), f.FieldDescriptorProto.GetTypeName())
+	valueField, err := g.mapValueField(f.FieldDescriptorProto)
 	if err != nil {
 		return err
 	}
-	var valueField *gdescriptor.FieldDescriptorProto
-	for _, ff := range mapEntry.GetField() {
-		if ff.GetName() == "value" {
-			valueField = ff
-			break
-		}
-	}
-	mapType, prefix, suffix := getReactMapType(valueField, true)
+	mapType, prefix, suffix := g.getReactMapType(valueField, true)
 	tempStart := `
-			Map<String,{{JavaValue}}> {{javaMap}} = {{messageName}}.get{{javaName}}Map();
+			Map<String,{{BoxedValue}}> {{javaMap}} = {{messageName}}.get{{javaName}}Map();
 			WritableMap {{innerMap}} = Arguments.createMap();
 			for(String k_{{javaMap}}: {{javaMap}}.keySet()){
 				{{JavaValue}} v_{{javaMap}} = {{javaMap}}.get(k_{{javaMap}});
@@ -492,50 +959,29 @@ func (g *generator) protoMapToMap(f *descriptor.Field, file *descriptor.File, ma
 		"innerMap":    innerMap,
 		"javaMap":     javaMap,
 		"JavaValue":   javaType,
+		"BoxedValue":  javaBoxedType(javaType),
 	})
 	tempEnd := `}
 	{{mapName}}.putMap("{{jsonName}}",{{innerMap}});`
 
-	if mapType == "Message" {
-		javaType := g.getJavaType(f.FieldDescriptorProto, file)
-		mesfield, _ := g.reg.LookupMsg(file.GetPackage(), valueField.GetTypeName())
-		tempStart := `WritableMap in_{{innerMap}}_map = Arguments.createMap();`
-		tempEnd := `{{innerMap}}.putMap(k_{{javaMap}},in_{{innerMap}}_map);
-			`
-		fasttemplate.Execute(tempStart, "{{", "}}", buf, map[string]interface{}{
-			"jsonName":    f.GetJsonName(),
-			"javaName":    strings.Title(f.GetJsonName()),
-			"mapName":     mapName,
-			"messageName": messageName,
-			"javaType":    javaType,
-			"innerMap":    innerMap,
-			"javaMap":     javaMap,
-		})
-		g.protoMessageToReactMap(mesfield, file, fmt.Sprintf("in_%s_map", innerMap), fmt.Sprintf("v_%s", javaMap), buf)
-		fasttemplate.Execute(tempEnd, "{{", "}}", buf, map[string]interface{}{
-			"jsonName":    f.GetJsonName(),
-			"javaName":    strings.Title(f.GetJsonName()),
-			"mapName":     mapName,
-			"messageName": messageName,
-			"innerMap":    innerMap,
-			"javaMap":     javaMap,
-		})
-	} else {
-		temp := `{{innerMap}}.put{{mapType}}(k_{{javaMap}},{{prefix}}v_{{javaMap}}{{suffix}});
+	temp := `{{innerMap}}.put{{mapType}}(k_{{javaMap}},{{prefix}}v_{{javaMap}}{{suffix}});
+		`
+	if mapType == "Value" {
+		temp = `putValue({{innerMap}}, k_{{javaMap}}, v_{{javaMap}});
 		`
-		fasttemplate.Execute(temp, "{{", "}}", buf, map[string]interface{}{
-			"jsonName":    f.GetJsonName(),
-			"javaName":    strings.Title(f.GetJsonName()),
-			"mapName":     mapName,
-			"messageName": messageName,
-			"innerMap":    innerMap,
-			"javaMap":     javaMap,
-			"JavaValue":   javaType,
-			"mapType":     mapType,
-			"suffix":      suffix,
-			"prefix":      prefix,
-		})
 	}
+	fasttemplate.Execute(temp, "{{", "}}", buf, map[string]interface{}{
+		"jsonName":    f.GetJsonName(),
+		"javaName":    strings.Title(f.GetJsonName()),
+		"mapName":     mapName,
+		"messageName": messageName,
+		"innerMap":    innerMap,
+		"javaMap":     javaMap,
+		"JavaValue":   javaType,
+		"mapType":     mapType,
+		"suffix":      suffix,
+		"prefix":      prefix,
+	})
 
 	fasttemplate.Execute(tempEnd, "{{", "}}", buf, map[string]interface{}{
 		"jsonName": f.GetJsonName(),
@@ -549,7 +995,7 @@ func (g *generator) protoMapToMap(f *descriptor.Field, file *descriptor.File, ma
 func (g *generator) protoArrayToReactMap(f *descriptor.Field, file *descriptor.File, mapName string, messageName string, buf io.Writer) error {
 	innerArray := fmt.Sprintf("%s_%s", mapName, f.GetJsonName())
 
-	mapType, prefix, suffix := getReactMapType(f.FieldDescriptorProto, true)
+	mapType, prefix, suffix := g.getReactMapType(f.FieldDescriptorProto, true)
 	tempStart := `
 	WritableArray {{innerArray}} = Arguments.createArray();
 	for({{JavaType}} value_{{innerArray}} : {{messageName}}.get{{javaName}}List()){
@@ -568,42 +1014,21 @@ func (g *generator) protoArrayToReactMap(f *descriptor.Field, file *descriptor.F
 		"JavaType":    javaType,
 	})
 
-	if mapType == "Message" {
-		javaType := g.getJavaType(f.FieldDescriptorProto, file)
-		mesfield, _ := g.reg.LookupMsg(file.GetPackage(), f.GetTypeName())
-		tempStart := `WritableMap array_{{innerArray}}_map = Arguments.createMap();`
-		tempEnd := `{{innerArray}}.pushMap(array_{{innerArray}}_map);
-		`
-		fasttemplate.Execute(tempStart, "{{", "}}", buf, map[string]interface{}{
-			"jsonName":    f.GetJsonName(),
-			"javaName":    strings.Title(f.GetJsonName()),
-			"mapName":     mapName,
-			"messageName": messageName,
-			"javaType":    javaType,
-			"innerArray":  innerArray,
-		})
-		g.protoMessageToReactMap(mesfield, file, fmt.Sprintf("array_%s_map", innerArray), fmt.Sprintf("value_%s", innerArray), buf)
-		fasttemplate.Execute(tempEnd, "{{", "}}", buf, map[string]interface{}{
-			"jsonName":    f.GetJsonName(),
-			"javaName":    strings.Title(f.GetJsonName()),
-			"mapName":     mapName,
-			"messageName": messageName,
-			"innerArray":  innerArray,
-		})
-	} else {
-		tempStr := `{{innerArray}}.push{{mapType}}({{prefix}}value_{{innerArray}}{{suffix}});`
-		fasttemplate.Execute(tempStr, "{{", "}}", buf, map[string]interface{}{
-			"jsonName":    f.GetJsonName(),
-			"javaName":    strings.Title(f.GetJsonName()),
-			"mapName":     mapName,
-			"messageName": messageName,
-			"innerArray":  innerArray,
-			"JavaType":    javaType,
-			"mapType":     mapType,
-			"prefix":      prefix,
-			"suffix":      suffix,
-		})
+	tempStr := `{{innerArray}}.push{{mapType}}({{prefix}}value_{{innerArray}}{{suffix}});`
+	if mapType == "Value" {
+		tempStr = `pushValue({{innerArray}}, value_{{innerArray}});`
 	}
+	fasttemplate.Execute(tempStr, "{{", "}}", buf, map[string]interface{}{
+		"jsonName":    f.GetJsonName(),
+		"javaName":    strings.Title(f.GetJsonName()),
+		"mapName":     mapName,
+		"messageName": messageName,
+		"innerArray":  innerArray,
+		"JavaType":    javaType,
+		"mapType":     mapType,
+		"prefix":      prefix,
+		"suffix":      suffix,
+	})
 
 	fasttemplate.Execute(tempEnd, "{{", "}}", buf, map[string]interface{}{
 		"jsonName":    f.GetJsonName(),
@@ -617,39 +1042,32 @@ func (g *generator) protoArrayToReactMap(f *descriptor.Field, file *descriptor.F
 }
 
 func (g *generator) protoMessageFieldToReactMap(f *descriptor.Field, mes *descriptor.Message, file *descriptor.File, mapName string, messageName string, buf io.Writer) error {
-	mapType, prefix, suffix := getReactMapType(f.FieldDescriptorProto, true)
+	mapType, prefix, suffix := g.getReactMapType(f.FieldDescriptorProto, true)
 	isArray := f.GetLabel() == gdescriptor.FieldDescriptorProto_LABEL_REPEATED
 	if g.isMap(f, file) {
 		g.protoMapToMap(f, file, mapName, messageName, buf)
 	} else if isArray {
 		g.protoArrayToReactMap(f, file, mapName, messageName, buf)
-	} else if mapType == "Message" {
-		javaType := g.getJavaType(f.FieldDescriptorProto, file)
-		mesfield, _ := g.reg.LookupMsg(file.GetPackage(), f.GetTypeName())
-		tempStart := `
-			WritableMap {{mapName}}_{{jsonName}} = Arguments.createMap();
-			{{javaType}} {{messageName}}_{{jsonName}} = {{messageName}}.get{{javaName}}();
-			`
-		tempEnd := `{{mapName}}.putMap("{{jsonName}}",{{mapName}}_{{jsonName}});
-
-			`
-		fasttemplate.Execute(tempStart, "{{", "}}", buf, map[string]interface{}{
-			"jsonName":    f.GetJsonName(),
-			"javaName":    strings.Title(f.GetJsonName()),
-			"mapName":     mapName,
-			"messageName": messageName,
-			"javaType":    javaType,
-		})
-		g.protoMessageToReactMap(mesfield, file, fmt.Sprintf("%s_%s", mapName, f.GetJsonName()), fmt.Sprintf("%s_%s", messageName, f.GetJsonName()), buf)
-		fasttemplate.Execute(tempEnd, "{{", "}}", buf, map[string]interface{}{
-			"jsonName":    f.GetJsonName(),
-			"javaName":    strings.Title(f.GetJsonName()),
-			"mapName":     mapName,
-			"messageName": messageName,
-		})
 	} else {
 		temp := `{{mapName}}.put{{mapType}}("{{jsonName}}",{{prefix}}{{messageName}}.get{{javaName}}(){{suffix}});
 		`
+		if mapType == "Value" {
+			temp = `putValue({{mapName}}, "{{jsonName}}", {{messageName}}.get{{javaName}}());
+		`
+		}
+		// Unset messages are left out, the default instance of a recursive
+		// message would be converted forever.
+		if f.GetType() == gdescriptor.FieldDescriptorProto_TYPE_MESSAGE && f.OneofIndex == nil {
+			temp = `if ({{messageName}}.has{{javaName}}()) {
+			` + temp + `}`
+			if isWellKnownJSON(f.GetTypeName()) {
+				temp += ` else {
+			{{mapName}}.putNull("{{jsonName}}");
+		}`
+			}
+			temp += `
+		`
+		}
 		fasttemplate.Execute(temp, "{{", "}}", buf, map[string]interface{}{
 			"jsonName":    f.GetJsonName(),
 			"javaName":    strings.Title(f.GetJsonName()),
@@ -705,51 +1123,187 @@ func (g *generator) protoMessageToReactMap(mes *descriptor.Message, file *descri
 	return nil
 }
 
+// writeConverters writes the fromReadableMap and toWritableMap methods of m,
+// nested messages are converted by calling theirs.
+func (g *generator) writeConverters(m *descriptor.Message, buf io.Writer) error {
+	javaType := g.javaMessageName(m)
+	fmt.Fprintf(buf, "\n    public static %s %s(ReadableMap map) {\n        %s.Builder builder = %s.newBuilder();\n", javaType, g.javaFromMapName(m), javaType, javaType)
+	if err := g.readableMapToBuilder(m, m.File, "map", "builder", buf); err != nil {
+		return err
+	}
+	fmt.Fprintf(buf, "\n        return builder.build();\n    }\n\n    public static WritableMap toWritableMap(%s message) {\n        WritableMap map = Arguments.createMap();\n", javaType)
+	if err := g.protoMessageToReactMap(m, m.File, "map", "message", buf); err != nil {
+		return err
+	}
+	fmt.Fprint(buf, "\n        return map;\n    }\n")
+	return nil
+}
+
+// writeAnyRegistry writes anyFromMap and anyToMap, packing and unpacking
+// messages to and from the JSON form of google.protobuf.Any.
+func (g *generator) writeAnyRegistry(messages []*descriptor.Message, buf io.Writer) {
+	fmt.Fprint(buf, `
+    private static com.google.protobuf.Any anyFromMap(ReadableMap map) {
+        String typeUrl = map.getString("@type");
+        switch (typeUrl) {
+`)
+	for _, m := range messages {
+		fmt.Fprintf(buf, "        case %q:\n            return %s;\n", anyTypeURL(m), g.javaPackAny(g.javaFromMap(m)+"(map)"))
+	}
+	wkts := anyWellKnownTypes(messages)
+	for _, t := range wkts {
+		fmt.Fprintf(buf, "        case %q:\n            return %s;\n", wellKnownTypeURL(t), g.javaPackAny(g.javaWellKnownFromAny(t)))
+	}
+	fmt.Fprint(buf, `        default:
+            throw new IllegalArgumentException("unknown type " + typeUrl);
+        }
+    }
+
+    private static WritableMap anyToMap(com.google.protobuf.Any any) {
+        WritableMap out;
+        try {
+            switch (any.getTypeUrl()) {
+`)
+	for _, m := range messages {
+		fmt.Fprintf(buf, "            case %q:\n                out = %s(%s);\n                break;\n", anyTypeURL(m), g.javaToMap(m), g.javaUnpackAny(g.javaMessageName(m)))
+	}
+	for _, t := range wkts {
+		fmt.Fprintf(buf, "            case %q:\n                out = Arguments.createMap();\n                %s;\n                break;\n", wellKnownTypeURL(t),
+			g.javaWellKnownToAny(t, g.javaUnpackAny("com.google.protobuf."+wellKnownName(t))))
+	}
+	fmt.Fprint(buf, `            default:
+                throw new IllegalArgumentException("unknown type " + any.getTypeUrl());
+            }
+        } catch (com.google.protobuf.InvalidProtocolBufferException e) {
+            throw new IllegalArgumentException(e);
+        }
+        out.putString("@type", any.getTypeUrl());
+        return out;
+    }
+`)
+}
+
+// javaPackAny returns the expression packing the message msg in a
+// google.protobuf.Any. The lite runtime has no Any.pack, the type URL is
+// that of the case it is called in.
+func (g *generator) javaPackAny(msg string) string {
+	if g.Runtime == "lite" {
+		return "com.google.protobuf.Any.newBuilder().setTypeUrl(typeUrl).setValue(" + msg + ".toByteString()).build()"
+	}
+	return "com.google.protobuf.Any.pack(" + msg + ")"
+}
+
+// javaUnpackAny returns the expression unpacking the message of the class
+// javaType from any.
+func (g *generator) javaUnpackAny(javaType string) string {
+	if g.Runtime == "lite" {
+		return javaType + ".parseFrom(any.getValue())"
+	}
+	return "any.unpack(" + javaType + ".class)"
+}
+
+// javaWellKnownFromAny returns the expression converting the value of the
+// JSON form of an Any holding the well-known type typeName, in map.
+func (g *generator) javaWellKnownFromAny(typeName string) string {
+	switch typeName {
+	case wktEmpty:
+		return "com.google.protobuf.Empty.getDefaultInstance()"
+	case wktFieldMask:
+		g.useHelper("fieldMask", javaFieldMaskHelpers)
+		return `fieldMaskFromString(map.getString("value"))`
+	}
+	mapType, prefix, suffix, _ := g.javaWellKnownType(typeName, false)
+	return fmt.Sprintf(`%smap.get%s("value")%s`, prefix, mapType, suffix)
+}
+
+// javaWellKnownToAny returns the statement putting the well-known type
+// typeName msg in out, the JSON form of the Any holding it.
+func (g *generator) javaWellKnownToAny(typeName, msg string) string {
+	switch typeName {
+	case wktEmpty:
+		return `out.putMap("value", Arguments.createMap())`
+	case wktFieldMask:
+		g.useHelper("fieldMask", javaFieldMaskHelpers)
+		return fmt.Sprintf(`out.putString("value", fieldMaskToString(%s))`, msg)
+	}
+	mapType, prefix, suffix, _ := g.javaWellKnownType(typeName, true)
+	if mapType == "Value" {
+		return fmt.Sprintf(`putValue(out, "value", %s)`, msg)
+	}
+	return fmt.Sprintf(`out.put%s("value", %s%s%s)`, mapType, prefix, msg, suffix)
+}
+
+// javaResult returns what a call resolves with when it returns the response
+// held in the variable name.
+func (g *generator) javaResult(response *descriptor.Message, name string) string {
+	if isEmptyMessage(response) {
+		return "null"
+	}
+	return g.javaToMap(response) + "(" + name + ")"
+}
+
 func (g *generator) generateUnaryMethod(m *descriptor.Method, file *descriptor.File, buf io.Writer) error {
 	name := ToJsonName(m.GetName())
 	met := `
 	@ReactMethod
-    public void {{methodName}}(ReadableMap in, final Promise promise) {
+    public void {{methodName}}(ReadableMap in, @Nullable ReadableMap options, final Promise promise) {
         ManagedChannel ch = this.engine.byServiceName({{serviceName}}Grpc.SERVICE_NAME);
-        {{serviceName}}Grpc.{{serviceName}}FutureStub stub = this.engine.attachHeaders({{serviceName}}Grpc.newFutureStub(ch));
-        {{requestName}}.Builder builder = {{requestName}}.newBuilder();
+        final GrpcCallOptions.Capture capture = new GrpcCallOptions.Capture();
+        final boolean withMetadata = GrpcCallOptions.withMetadata(options);
+        {{serviceName}}Grpc.{{serviceName}}FutureStub stub = GrpcCallOptions.apply(this.engine.attachHeaders({{serviceName}}Grpc.newFutureStub(ch)), options)
+                .withInterceptors(capture.interceptor());
+        final {{requestName}} request;
+        try {
+            request = {{fromMap}}(in);
+        } catch (IllegalArgumentException e) {
+            GrpcErrors.rejectInvalid(promise, e.getMessage());
+            return;
+        }
 `
 
 	fasttemplate.Execute(met, "{{", "}}", buf, map[string]interface{}{
 		"serviceName": m.Service.GetName(),
 		"methodName":  name,
-		"requestName": m.RequestType.GetName(),
+		"requestName": g.javaMessageName(m.RequestType),
+		"fromMap":     g.javaFromMap(m.RequestType),
 	})
 
-	g.readableMapToBuilder(m.RequestType, file, "in", "builder", buf)
-	futureTemp := `
-        Futures.addCallback(stub.{{methodName}}(builder.build()), new FutureCallback<{{responseName}}>() {
+	futureTemp := `        final String requestId = GrpcCallOptions.requestId(options);
+        if (requestId != null && unaryCalls.containsKey(requestId)) {
+            GrpcErrors.rejectInFlight(promise, requestId);
+            return;
+        }
+        final ListenableFuture<{{responseName}}> future = stub.{{methodName}}(request);
+        if (requestId != null) {
+            unaryCalls.put(requestId, future);
+        }
+        Futures.addCallback(future, new FutureCallback<{{responseName}}>() {
             @Override
             public void onSuccess(@Nullable {{responseName}} result) {
+                forget(requestId, future);
             	if(result == null){
             		promise.reject("null","response is null");
             		return;
             	}
-                WritableMap out = Arguments.createMap();
                 `
 	fasttemplate.Execute(futureTemp, "{{", "}}", buf, map[string]interface{}{
-		"responseName": m.ResponseType.GetName(),
+		"responseName": g.javaMessageName(m.ResponseType),
 		"methodName":   name,
 	})
 
-	if err := g.protoMessageToReactMap(m.ResponseType, file, "out", "result", buf); err != nil {
-		return err
-	}
-
-	_, err := buf.Write([]byte(`promise.resolve(out);
+	_, err := fasttemplate.Execute(`WritableMap response = {{result}};
+                promise.resolve(withMetadata ? capture.wrap(response) : response);
             }
 
             @Override
             public void onFailure(Throwable t) {
-                promise.reject(t);
+                forget(requestId, future);
+                GrpcErrors.reject(promise, t);
             }
         });
-	}`))
+	}`, "{{", "}}", buf, map[string]interface{}{
+		"result": g.javaResult(m.ResponseType, "result"),
+	})
 	return err
 }
 
@@ -757,54 +1311,69 @@ func (g *generator) generateOutputStreamMethod(m *descriptor.Method, file *descr
 	name := ToJsonName(m.GetName())
 	met := `
 	@ReactMethod
-    public void {{methodName}}(ReadableMap in, final Promise promise) {
-        {{requestName}}.Builder builder = {{requestName}}.newBuilder();
+    public void {{methodName}}(ReadableMap in, @Nullable ReadableMap options, final Promise promise) {
+        final {{requestName}} request;
+        try {
+            request = {{fromMap}}(in);
+        } catch (IllegalArgumentException e) {
+            GrpcErrors.rejectInvalid(promise, e.getMessage());
+            return;
+        }
 		final String eventID = java.util.UUID.randomUUID().toString();
+        final GrpcCallOptions.Capture capture = new GrpcCallOptions.Capture();
 `
 
 	fasttemplate.Execute(met, "{{", "}}", buf, map[string]interface{}{
 		"serviceName": m.Service.GetName(),
 		"methodName":  name,
-		"requestName": m.RequestType.GetName(),
+		"requestName": g.javaMessageName(m.RequestType),
+		"fromMap":     g.javaFromMap(m.RequestType),
 	})
 
-	g.readableMapToBuilder(m.RequestType, file, "in", "builder", buf)
+	streamStart := `ClientResponseObserver<{{requestName}}, {{responseName}}> observer = new ClientResponseObserver<{{requestName}}, {{responseName}}>() {
+            @Override
+            public void beforeStart(ClientCallStreamObserver<{{requestName}}> call) {
+                streamCalls.put(eventID, call);
+            }
 
-	streamStart := `StreamObserver<{{responseName}}> observer = new StreamObserver<{{responseName}}>() {
             @Override
-            public void onNext({{responseName}} value) {
-            	WritableMap out = Arguments.createMap();
-           `
+            public void onNext({{responseName}} value) {`
 	fasttemplate.Execute(streamStart, "{{", "}}", buf, map[string]interface{}{
 		"serviceName":  m.Service.GetName(),
-		"responseName": m.ResponseType.GetName(),
-		"requestName":  m.RequestType.GetName(),
+		"responseName": g.javaMessageName(m.ResponseType),
+		"requestName":  g.javaMessageName(m.RequestType),
 	})
-	if err := g.protoMessageToReactMap(m.ResponseType, file, "out", "value", buf); err != nil {
-		return err
-	}
 
 	streamEnd := `
 				WritableMap data = Arguments.createMap();
                 data.putBoolean("done", false);
-                data.putMap("data", out);
+                data.putMap("data", {{toMap}}(value));
                 getReactApplicationContext().getJSModule(DeviceEventManagerModule.RCTDeviceEventEmitter.class)
                         .emit(eventID, data);
             }
 
             @Override
             public void onError(Throwable t) {
+                // Calls cancelled from javascript already sent their last event.
+                if (streamCalls.remove(eventID) == null) {
+                    return;
+                }
                 WritableMap data = Arguments.createMap();
                 data.putBoolean("done", true);
-                data.putString("error", t.getMessage());
+                GrpcErrors.putError(data, t);
+                data.putMap("trailers", capture.trailers());
                 getReactApplicationContext().getJSModule(DeviceEventManagerModule.RCTDeviceEventEmitter.class)
                         .emit(eventID, data);
             }
 
             @Override
             public void onCompleted() {
+                if (streamCalls.remove(eventID) == null) {
+                    return;
+                }
                 WritableMap data = Arguments.createMap();
                 data.putBoolean("done", true);
+                data.putMap("trailers", capture.trailers());
                 getReactApplicationContext().getJSModule(DeviceEventManagerModule.RCTDeviceEventEmitter.class)
                         .emit(eventID, data);
             }
@@ -812,17 +1381,19 @@ func (g *generator) generateOutputStreamMethod(m *descriptor.Method, file *descr
 	`
 	fasttemplate.Execute(streamEnd, "{{", "}}", buf, map[string]interface{}{
 		"serviceName":  m.Service.GetName(),
-		"responseName": m.ResponseType.GetName(),
-		"requestName":  m.RequestType.GetName(),
+		"responseName": g.javaMessageName(m.ResponseType),
+		"requestName":  g.javaMessageName(m.RequestType),
+		"toMap":        g.javaToMap(m.ResponseType),
 	})
 	endTemp := `
 		ManagedChannel ch = this.engine.byServiceName({{serviceName}}Grpc.SERVICE_NAME);
-        this.engine.attachHeaders({{serviceName}}Grpc.newStub(ch)).{{methodName}}(builder.build(),observer);
+        GrpcCallOptions.apply(this.engine.attachHeaders({{serviceName}}Grpc.newStub(ch)), options)
+                .withInterceptors(capture.interceptor()).{{methodName}}(request,observer);
 		promise.resolve(eventID);
     }`
 	_, err := fasttemplate.Execute(endTemp, "{{", "}}", buf, map[string]interface{}{
 		"serviceName":  m.Service.GetName(),
-		"responseName": m.ResponseType.GetName(),
+		"responseName": g.javaMessageName(m.ResponseType),
 		"methodName":   name,
 	})
 
@@ -835,71 +1406,81 @@ func (g *generator) generateBiStream(m *descriptor.Method, file *descriptor.File
 	classTemplateStart := `
 	private class {{className}} {
         public String id;
-        StreamObserver<{{responseType}}> incoming;
+        ClientResponseObserver<{{requestType}}, {{responseType}}> incoming;
         StreamObserver<{{requestType}}> outgoing;
+        ClientCallStreamObserver<{{requestType}}> call;
+        volatile StatusRuntimeException cancelled;
+        // halfClosed is set by "complete", the stream stays registered until the server ends it.
+        boolean halfClosed;
+        final GrpcCallOptions.Capture capture = new GrpcCallOptions.Capture();
 
         {{className}}() {
             this.id = java.util.UUID.randomUUID().toString();
-            this.incoming = new StreamObserver<{{responseType}}>() {
+            this.incoming = new ClientResponseObserver<{{requestType}}, {{responseType}}>() {
                 @Override
-                public void onNext({{responseType}} value) {
-                	WritableMap in = Arguments.createMap();
-                   `
+                public void beforeStart(ClientCallStreamObserver<{{requestType}}> call) {
+                    {{className}}.this.call = call;
+                }
+
+                @Override
+                public void onNext({{responseType}} value) {`
 	fasttemplate.Execute(classTemplateStart, "{{", "}}", buf, map[string]interface{}{
 		"serviceName":  m.Service.GetName(),
 		"methodName":   name,
-		"requestType":  m.RequestType.GetName(),
-		"responseType": m.ResponseType.GetName(),
+		"requestType":  g.javaMessageName(m.RequestType),
+		"responseType": g.javaMessageName(m.ResponseType),
 		"className":    className,
 	})
-	g.protoMessageToReactMap(m.ResponseType, file, "in", "value", buf)
 	classTemplateEnd := `
-
                     WritableMap data = Arguments.createMap();
                     data.putBoolean("done", false);
-                    data.putMap("data", in);
+                    data.putMap("data", {{toMap}}(value));
                     getReactApplicationContext().getJSModule(DeviceEventManagerModule.RCTDeviceEventEmitter.class)
                             .emit(id, data);
                 }
 
                 @Override
                 public void onError(Throwable t) {
+                    {{className}}Map.remove(id);
                     WritableMap data = Arguments.createMap();
                     data.putBoolean("done", true);
-                    data.putString("error", t.getMessage());
+                    // Streams ended from javascript fail with the status it gave.
+                    GrpcErrors.putError(data, cancelled != null ? cancelled : t);
+                    data.putMap("trailers", capture.trailers());
                     getReactApplicationContext().getJSModule(DeviceEventManagerModule.RCTDeviceEventEmitter.class)
                             .emit(id, data);
                 }
 
                 @Override
                 public void onCompleted() {
+                    {{className}}Map.remove(id);
                     WritableMap data = Arguments.createMap();
                     data.putBoolean("done", true);
+                    data.putMap("trailers", capture.trailers());
                     getReactApplicationContext().getJSModule(DeviceEventManagerModule.RCTDeviceEventEmitter.class)
                             .emit(id, data);
                 }
             };
         }
     }
-    private Map<String, {{className}}> {{className}}Map;
+    private final Map<String, {{className}}> {{className}}Map = new ConcurrentHashMap<>();
 `
 	fasttemplate.Execute(classTemplateEnd, "{{", "}}", buf, map[string]interface{}{
 		"serviceName":  m.Service.GetName(),
 		"methodName":   name,
-		"requestType":  m.RequestType.GetName(),
-		"responseType": m.ResponseType.GetName(),
+		"requestType":  g.javaMessageName(m.RequestType),
+		"responseType": g.javaMessageName(m.ResponseType),
 		"className":    className,
+		"toMap":        g.javaToMap(m.ResponseType),
 	})
 
 	startMethod := `
 	@ReactMethod
-    public void {{methodName}}(Promise promise) {
+    public void {{methodName}}(@Nullable ReadableMap options, Promise promise) {
         {{className}} streamer = new {{className}}();
         ManagedChannel ch = this.engine.byServiceName({{serviceName}}Grpc.SERVICE_NAME);
-        streamer.outgoing = this.engine.attachHeaders({{serviceName}}Grpc.newStub(ch)).{{grpcName}}(streamer.incoming);
-        if ({{className}}Map == null) {
-            {{className}}Map = new HashMap<>();
-        }
+        streamer.outgoing = GrpcCallOptions.apply(this.engine.attachHeaders({{serviceName}}Grpc.newStub(ch)), options)
+                .withInterceptors(streamer.capture.interceptor()).{{grpcName}}(streamer.incoming);
         {{className}}Map.put(streamer.id, streamer);
         promise.resolve(streamer.id);
     }
@@ -908,55 +1489,222 @@ func (g *generator) generateBiStream(m *descriptor.Method, file *descriptor.File
 		"serviceName":  m.Service.GetName(),
 		"methodName":   fmt.Sprintf("start%s", strings.Title(name)),
 		"grpcName":     name,
-		"requestType":  m.RequestType.GetName(),
-		"responseType": m.ResponseType.GetName(),
+		"requestType":  g.javaMessageName(m.RequestType),
+		"responseType": g.javaMessageName(m.ResponseType),
 		"className":    className,
 	})
 
 	methodStart := `
+    /**
+     * Sends in to the stream id, or half-closes it after sending in, if any, with "complete", or
+     * cancels it with the code and message of in with "error". The promise resolves once gRPC
+     * took the message and rejects once the stream is closed, or half-closed but for "error".
+     */
    @ReactMethod
-    public void {{grpcName}}(String id, String action, ReadableMap in) {
-        if ({{className}}Map.containsKey(id)) {
-            {{className}} streamer = {{className}}Map.get(id);
+    public void {{grpcName}}(String id, String action, @Nullable ReadableMap in, Promise promise) {
+        {{className}} streamer = action.equals("error") ? {{className}}Map.remove(id) : {{className}}Map.get(id);
+        if (streamer == null) {
+            promise.reject("not_found", "stream " + id + " is not open");
+            return;
+        }
+        if (streamer.halfClosed && !action.equals("error")) {
+            promise.reject("half_closed", "stream " + id + " is half-closed");
+            return;
+        }
+        try {
             switch (action) {
                 case "complete":
+                    if (in != null) {
+                        streamer.outgoing.onNext({{fromMap}}(in));
+                    }
+                    streamer.halfClosed = true;
                     streamer.outgoing.onCompleted();
-                    {{className}}Map.remove(id);
                     break;
                 case "error":
-                    streamer.outgoing.onError(new Throwable());
-					{{className}}Map.remove(id);
+                    streamer.cancelled = GrpcErrors.fromJS(in);
+                    streamer.outgoing.onError(streamer.cancelled);
                     break;
                 default:
-                    {{requestType}}.Builder builder = {{requestType}}.newBuilder();
+                    streamer.outgoing.onNext({{fromMap}}(in));
+                    break;
+            }
+            promise.resolve(null);
+        } catch (IllegalArgumentException e) {
+            GrpcErrors.rejectInvalid(promise, e.getMessage());
+        } catch (RuntimeException e) {
+            GrpcErrors.reject(promise, e);
+        }
+    }
 	`
 	fasttemplate.Execute(methodStart, "{{", "}}", buf, map[string]interface{}{
 		"serviceName":  m.Service.GetName(),
 		"methodName":   fmt.Sprintf("start%s", strings.Title(name)),
 		"grpcName":     name,
-		"requestType":  m.RequestType.GetName(),
-		"responseType": m.ResponseType.GetName(),
+		"requestType":  g.javaMessageName(m.RequestType),
+		"responseType": g.javaMessageName(m.ResponseType),
+		"className":    className,
+		"fromMap":      g.javaFromMap(m.RequestType),
+	})
+	return nil
+}
+
+func (g *generator) generateInputStreamMethod(m *descriptor.Method, file *descriptor.File, buf io.Writer) error {
+	name := ToJsonName(m.GetName())
+	className := fmt.Sprintf("%sStreamer", strings.Title(name))
+	classTemplateStart := `
+	private class {{className}} {
+        public String id;
+        ClientResponseObserver<{{requestType}}, {{responseType}}> incoming;
+        StreamObserver<{{requestType}}> outgoing;
+        ClientCallStreamObserver<{{requestType}}> call;
+        {{responseType}} response;
+        Throwable error;
+        boolean finished;
+        boolean withMetadata;
+        // halfClosed is set by "complete", the stream stays registered until the server ends it.
+        boolean halfClosed;
+        Promise promise;
+        final GrpcCallOptions.Capture capture = new GrpcCallOptions.Capture();
+
+        {{className}}() {
+            this.id = java.util.UUID.randomUUID().toString();
+            this.incoming = new ClientResponseObserver<{{requestType}}, {{responseType}}>() {
+                @Override
+                public void beforeStart(ClientCallStreamObserver<{{requestType}}> call) {
+                    {{className}}.this.call = call;
+                }
+
+                @Override
+                public void onNext({{responseType}} value) {
+                    response = value;
+                }
+
+                @Override
+                public void onError(Throwable t) {
+                    error = t;
+                    finish();
+                }
+
+                @Override
+                public void onCompleted() {
+                    finish();
+                }
+            };
+        }
+
+        synchronized void finish() {
+            {{className}}Map.remove(id, this);
+            finished = true;
+            settle();
+        }
+
+        synchronized void await(Promise promise) {
+            this.promise = promise;
+            settle();
+        }
+
+        private void settle() {
+            if (!finished || promise == null) {
+                return;
+            }
+            if (error != null) {
+                GrpcErrors.reject(promise, error);
+                return;
+            }
+            if (response == null) {
+                promise.reject("null", "response is null");
+                return;
+            }
+            `
+	fasttemplate.Execute(classTemplateStart, "{{", "}}", buf, map[string]interface{}{
+		"requestType":  g.javaMessageName(m.RequestType),
+		"responseType": g.javaMessageName(m.ResponseType),
 		"className":    className,
 	})
+	classTemplateEnd := `WritableMap result = {{result}};
+            promise.resolve(withMetadata ? capture.wrap(result) : result);
+        }
+    }
+    private final Map<String, {{className}}> {{className}}Map = new ConcurrentHashMap<>();
+`
+	fasttemplate.Execute(classTemplateEnd, "{{", "}}", buf, map[string]interface{}{
+		"className": className,
+		"result":    g.javaResult(m.ResponseType, "response"),
+	})
 
-	g.readableMapToBuilder(m.RequestType, file, "in", "builder", buf)
+	startMethod := `
+	@ReactMethod
+    public void {{methodName}}(@Nullable ReadableMap options, Promise promise) {
+        {{className}} streamer = new {{className}}();
+        streamer.withMetadata = GrpcCallOptions.withMetadata(options);
+        ManagedChannel ch = this.engine.byServiceName({{serviceName}}Grpc.SERVICE_NAME);
+        streamer.outgoing = GrpcCallOptions.apply(this.engine.attachHeaders({{serviceName}}Grpc.newStub(ch)), options)
+                .withInterceptors(streamer.capture.interceptor()).{{grpcName}}(streamer.incoming);
+        {{className}}Map.put(streamer.id, streamer);
+        promise.resolve(streamer.id);
+    }
+	`
+	fasttemplate.Execute(startMethod, "{{", "}}", buf, map[string]interface{}{
+		"serviceName": m.Service.GetName(),
+		"methodName":  fmt.Sprintf("start%s", strings.Title(name)),
+		"grpcName":    name,
+		"className":   className,
+	})
 
-	methodEnd := `
-                    streamer.outgoing.onNext(builder.build());
+	methodStart := `
+    /**
+     * Sends in to the stream id, or half-closes it after sending in, if any, with "complete", or
+     * cancels it with the code and message of in with "error". "complete" resolves the response,
+     * the other actions resolve once gRPC took the message and reject once the stream is closed,
+     * or half-closed but for "error".
+     */
+    @ReactMethod
+    public void {{grpcName}}(String id, String action, @Nullable ReadableMap in, Promise promise) {
+        {{className}} streamer = action.equals("error") ? {{className}}Map.remove(id) : {{className}}Map.get(id);
+        if (streamer == null) {
+            promise.reject("not_found", "stream " + id + " is not open");
+            return;
+        }
+        if (streamer.halfClosed && !action.equals("error")) {
+            promise.reject("half_closed", "stream " + id + " is half-closed");
+            return;
+        }
+        try {
+            switch (action) {
+                case "complete":
+                    if (in != null) {
+                        streamer.outgoing.onNext({{fromMap}}(in));
+                    }
+                    streamer.halfClosed = true;
+                    streamer.outgoing.onCompleted();
+                    streamer.await(promise);
+                    break;
+                case "error":
+                    streamer.outgoing.onError(GrpcErrors.fromJS(in));
+                    promise.resolve(null);
+                    break;
+                default:
+                    if (in == null) {
+                        GrpcErrors.rejectInvalid(promise, "stream " + id + " needs a message to send");
+                        return;
+                    }
+                    streamer.outgoing.onNext({{fromMap}}(in));
+                    promise.resolve(null);
                     break;
             }
+        } catch (IllegalArgumentException e) {
+            GrpcErrors.rejectInvalid(promise, e.getMessage());
+        } catch (RuntimeException e) {
+            GrpcErrors.reject(promise, e);
         }
     }
 	`
-	fasttemplate.Execute(methodEnd, "{{", "}}", buf, map[string]interface{}{
-		"serviceName":  m.Service.GetName(),
-		"methodName":   fmt.Sprintf("start%s", strings.Title(name)),
-		"grpcName":     name,
-		"requestType":  m.RequestType.GetName(),
-		"responseType": m.ResponseType.GetName(),
-		"className":    className,
+	_, err := fasttemplate.Execute(methodStart, "{{", "}}", buf, map[string]interface{}{
+		"grpcName":  name,
+		"fromMap":   g.javaFromMap(m.RequestType),
+		"className": className,
 	})
-	return nil
+	return err
 }
 
 func (g *generator) generateMethod(m *descriptor.Method, file *descriptor.File, buf io.Writer) error {
@@ -969,59 +1717,244 @@ func (g *generator) generateMethod(m *descriptor.Method, file *descriptor.File,
 		return g.generateOutputStreamMethod(m, file, buf)
 	} else if client && server {
 		return g.generateBiStream(m, file, buf)
-	} else {
-		panic("client side streaming not implemented")
 	}
-	return nil
+	return g.generateInputStreamMethod(m, file, buf)
 }
 
-func (g *generator) generate(file *descriptor.File) (string, error) {
+// generate returns the module of svc, declared in file. Modules are public so
+// that the GeneratedGrpcPackage of another package can create them, hence one
+// java file per service.
+func (g *generator) generate(file *descriptor.File, svc *descriptor.Service, classes []*javaConverters) (string, error) {
 	var buf bytes.Buffer
-	var pn = g.packageName
-	if pn == "" {
-		pn = file.Options.GetJavaPackage()
-	}
+	pn := g.moduleJavaPackage(file)
 	fasttemplate.Execute(javaHeader, "{{", "}}", &buf, map[string]interface{}{
 		"packageName":   pn,
-		"protoPackages": file.Options.GetJavaPackage(),
+		"protoPackages": javaPackageOf(file),
 	})
+	g.javaImports = map[string]bool{pn: true, javaPackageOf(file): true}
+	g.writeConvertersImports(pn, classes, &buf)
+	g.writeEngineImports(pn, ";", &buf)
 
-	for _, svc := range file.Services {
-		glog.V(1).Infof("Service Name %s", svc.GetName())
+	if g.TurboModule {
+		fmt.Fprintf(&buf, "import %s.*;\n", g.SpecPackage)
+	}
 
-		fasttemplate.Execute(serviceTop, "{{", "}}", &buf, map[string]interface{}{
-			"serviceName": svc.GetName(),
-		})
+	glog.V(1).Infof("Service Name %s", svc.GetName())
+	fasttemplate.Execute(serviceTop, "{{", "}}", &buf, map[string]interface{}{
+		"serviceName":     svc.GetName(),
+		"baseClass":       g.moduleBaseClass(svc),
+		"constantsMethod": g.constantsMethod(),
+	})
+	if hasCancellable(svc) {
+		buf.WriteString(serviceCancel)
+	}
+	g.writeCallRegistry(svc, &buf)
 
-		for _, m := range svc.Methods {
-			if err := g.generateMethod(m, file, &buf); err != nil {
-				return "", err
+	for _, m := range svc.Methods {
+		if err := g.generateMethod(m, file, &buf); err != nil {
+			return "", err
+		}
+	}
+	buf.WriteString("}")
+
+	return buf.String(), nil
+}
+
+// writeCallRegistry writes the onCatalystInstanceDestroy of svc, which
+// cancels the calls and streams still open, and the method counting the
+// bidirectional streams.
+func (g *generator) writeCallRegistry(svc *descriptor.Service, buf io.Writer) {
+	var streams, maps []string
+	for _, m := range svc.Methods {
+		if m.GetClientStreaming() {
+			streams = append(streams, strings.Title(ToJsonName(m.GetName()))+"StreamerMap")
+			if m.GetServerStreaming() {
+				maps = append(maps, strings.Title(ToJsonName(m.GetName()))+"StreamerMap")
 			}
 		}
+	}
+	cancellable := hasCancellable(svc)
+	if !cancellable && len(streams) == 0 {
+		return
+	}
+	fmt.Fprint(buf, "\n    @Override\n    public void onCatalystInstanceDestroy() {\n")
+	if cancellable {
+		fmt.Fprint(buf, `        for (String id : unaryCalls.keySet()) {
+            Future<?> future = unaryCalls.remove(id);
+            if (future != null) {
+                future.cancel(true);
+            }
+        }
+        for (String id : streamCalls.keySet()) {
+            ClientCallStreamObserver<?> call = streamCalls.remove(id);
+            if (call != null) {
+                call.cancel("module destroyed", null);
+            }
+        }
+`)
+	}
+	for _, name := range streams {
+		fmt.Fprintf(buf, `        for (String id : %s.keySet()) {
+            %s streamer = %s.remove(id);
+            if (streamer != null && streamer.call != null) {
+                streamer.call.cancel("module destroyed", null);
+            }
+        }
+`, name, strings.TrimSuffix(name, "Map"), name)
+	}
+	fmt.Fprint(buf, "    }\n")
+	if len(maps) == 0 {
+		return
+	}
+	fmt.Fprintf(buf, `
+    /**
+     * Resolves the number of open bidirectional streams, for diagnostics.
+     */
+    @ReactMethod
+    public void activeStreamCount(Promise promise) {
+        promise.resolve(%s);
+    }
+`, strings.Join(maps, ".size() + ")+".size()")
+}
 
-		buf.WriteString("}")
+// hasCancellable returns whether svc has unary or server streaming calls that
+// javascript can cancel.
+func hasCancellable(svc *descriptor.Service) bool {
+	for _, m := range svc.Methods {
+		if !m.GetClientStreaming() {
+			return true
+		}
 	}
+	return false
+}
 
-	return buf.String(), nil
+// hasClientStreaming returns whether svc has client streaming calls that are
+// not bidirectional.
+func hasClientStreaming(svc *descriptor.Service) bool {
+	for _, m := range svc.Methods {
+		if m.GetClientStreaming() && !m.GetServerStreaming() {
+			return true
+		}
+	}
+	return false
+}
+
+// hasBiStreaming returns whether svc has bidirectional streaming calls.
+func hasBiStreaming(svc *descriptor.Service) bool {
+	for _, m := range svc.Methods {
+		if m.GetClientStreaming() && m.GetServerStreaming() {
+			return true
+		}
+	}
+	return false
+}
+
+// checkReservedNames fails when a method of the services of files is named
+// like one the modules add to them: cancel for services with cancellable
+// calls and activeStreamCount for the ones with bidirectional streams.
+func checkReservedNames(files []*descriptor.File) error {
+	for _, file := range files {
+		for _, svc := range file.Services {
+			reserved := map[string]bool{"cancel": hasCancellable(svc), "activeStreamCount": hasBiStreaming(svc)}
+			for _, m := range svc.Methods {
+				if name := ToJsonName(m.GetName()); reserved[name] {
+					return fmt.Errorf("%s: method %s of service %s clashes with the generated %s method, rename the rpc", file.GetName(), m.GetName(), svc.GetName(), name)
+				}
+			}
+		}
+	}
+	return nil
 }
 
 func (g *generator) Generate(targets []*descriptor.File) ([]*plugin.CodeGeneratorResponse_File, error) {
-	var files []*plugin.CodeGeneratorResponse_File
+	switch g.Int64 {
+	case "", "string", "number":
+	default:
+		return nil, fmt.Errorf("unknown int64 mode %q", g.Int64)
+	}
+	switch g.Timestamp {
+	case "", "iso", "ms":
+	default:
+		return nil, fmt.Errorf("unknown timestamp mode %q", g.Timestamp)
+	}
+	switch g.Bytes {
+	case "", "base64", "utf8":
+	default:
+		return nil, fmt.Errorf("unknown bytes mode %q", g.Bytes)
+	}
+	switch g.Runtime {
+	case "", "full", "lite":
+	default:
+		return nil, fmt.Errorf("unknown runtime %q", g.Runtime)
+	}
+	if err := checkReservedNames(targets); err != nil {
+		return nil, err
+	}
+	// Modules are generated for the targets declaring services, the java
+	// converters for all of them.
+	var modules []*descriptor.File
 	for _, file := range targets {
-		str, err := g.generate(file)
+		if len(file.Services) > 0 {
+			modules = append(modules, file)
+		}
+	}
+	g.enginePackage = g.EnginePackage
+	if g.enginePackage == "" && len(modules) > 0 {
+		g.enginePackage = g.moduleJavaPackage(modules[0])
+	}
+	switch g.Target {
+	case "", "android":
+		switch g.Lang {
+		case "", "java":
+		case "kotlin":
+			classes := g.planConverters(targets)
+			files, err := g.generateJavaConverters(classes)
+			if err != nil {
+				return nil, err
+			}
+			kotlin, err := g.generateKotlin(modules, classes)
+			if err != nil {
+				return nil, err
+			}
+			return append(append(files, kotlin...), g.generateEngine(modules)...), nil
+		default:
+			return nil, fmt.Errorf("unknown lang %q", g.Lang)
+		}
+	case "objc":
+		files, err := g.generateObjC(modules)
 		if err != nil {
 			return nil, err
 		}
-		name := file.GetName()
-		ext := filepath.Ext(name)
-		base := strings.TrimSuffix(name, ext)
-		output := fmt.Sprintf("%sModule.java", ToFileName(base))
-		files = append(files, &plugin.CodeGeneratorResponse_File{
-			Name:    proto.String(output),
-			Content: proto.String(str),
-		})
-		glog.V(1).Infof("Will emit %s", output)
+		return append(files, g.generateAppleConfig(modules)...), nil
+	case "swift":
+		files, err := g.generateSwift(modules)
+		if err != nil {
+			return nil, err
+		}
+		return append(files, g.generateAppleConfig(modules)...), nil
+	default:
+		return nil, fmt.Errorf("unknown target %q", g.Target)
+	}
+	classes := g.planConverters(targets)
+	files, err := g.generateJavaConverters(classes)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range modules {
+		dir := ToFileName(filepath.Dir(file.GetName()))
+		for _, svc := range file.Services {
+			str, err := g.generate(file, svc, classes)
+			if err != nil {
+				return nil, err
+			}
+			output := filepath.Join(dir, fmt.Sprintf("%sModule.java", svc.GetName()))
+			files = append(files, &plugin.CodeGeneratorResponse_File{
+				Name:    proto.String(output),
+				Content: proto.String(str),
+			})
+			glog.V(1).Infof("Will emit %s", output)
+		}
 	}
 
-	return files, nil
+	return append(files, g.generateEngine(modules)...), nil
 }
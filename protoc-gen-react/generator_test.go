@@ -0,0 +1,274 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	protodescriptor "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/grpc-ecosystem/grpc-gateway/protoc-gen-grpc-gateway/descriptor"
+)
+
+const testProto = "example/example.proto"
+
+// testMethod returns a method of the test services taking and returning Item.
+func testMethod(name string, clientStreaming, serverStreaming bool) *protodescriptor.MethodDescriptorProto {
+	return &protodescriptor.MethodDescriptorProto{
+		Name:            proto.String(name),
+		InputType:       proto.String(".example.Item"),
+		OutputType:      proto.String(".example.Item"),
+		ClientStreaming: proto.Bool(clientStreaming),
+		ServerStreaming: proto.Bool(serverStreaming),
+	}
+}
+
+// generateTest runs the generator with opts on a file declaring an
+// ExampleService with a method of every kind, and an OtherService, and returns
+// the contents of the files it emits by base name.
+func generateTest(t *testing.T, opts Options) map[string]string {
+	req := &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{testProto},
+		ProtoFile: []*protodescriptor.FileDescriptorProto{{
+			Name:    proto.String(testProto),
+			Package: proto.String("example"),
+			Syntax:  proto.String("proto3"),
+			Options: &protodescriptor.FileOptions{JavaPackage: proto.String("com.example")},
+			MessageType: []*protodescriptor.DescriptorProto{{
+				Name: proto.String("Item"),
+				Field: []*protodescriptor.FieldDescriptorProto{{
+					Name:     proto.String("name"),
+					JsonName: proto.String("name"),
+					Number:   proto.Int32(1),
+					Label:    protodescriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:     protodescriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+				}},
+			}},
+			Service: []*protodescriptor.ServiceDescriptorProto{{
+				Name: proto.String("ExampleService"),
+				Method: []*protodescriptor.MethodDescriptorProto{
+					testMethod("GetItem", false, false),
+					testMethod("ListItems", false, true),
+					testMethod("UploadItems", true, false),
+					testMethod("SyncItems", true, true),
+				},
+			}, {
+				Name: proto.String("OtherService"),
+				Method: []*protodescriptor.MethodDescriptorProto{
+					testMethod("GetItem", false, false),
+					testMethod("WatchItems", false, true),
+				},
+			}},
+			SourceCodeInfo: &protodescriptor.SourceCodeInfo{},
+		}},
+	}
+	reg := descriptor.NewRegistry()
+	if err := reg.Load(req); err != nil {
+		t.Fatalf("reg.Load() failed: %v", err)
+	}
+	file, err := reg.LookupFile(testProto)
+	if err != nil {
+		t.Fatalf("reg.LookupFile(%q) failed: %v", testProto, err)
+	}
+	out, err := NewGenerator(reg, opts).Generate([]*descriptor.File{file})
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range out {
+		files[filepath.Base(f.GetName())] = f.GetContent()
+	}
+	return files
+}
+
+func TestGrpcErrorsDetails(t *testing.T) {
+	for _, tc := range []struct {
+		runtime string
+		details bool
+	}{
+		{runtime: "full", details: true},
+		{runtime: "lite", details: false},
+	} {
+		for _, lang := range []string{"java", "kotlin"} {
+			errors, ok := generateTest(t, Options{Lang: lang, Runtime: tc.runtime})["GrpcErrors.java"]
+			if !ok {
+				t.Fatalf("runtime=%s lang=%s: GrpcErrors.java not generated", tc.runtime, lang)
+			}
+			if got := strings.Contains(errors, "com.google.rpc"); got != tc.details {
+				t.Errorf("runtime=%s lang=%s: GrpcErrors.java uses com.google.rpc = %v, want %v", tc.runtime, lang, got, tc.details)
+			}
+			if got := strings.Contains(errors, "STATUS_DETAILS_KEY"); got != tc.details {
+				t.Errorf("runtime=%s lang=%s: GrpcErrors.java decodes grpc-status-details-bin = %v, want %v", tc.runtime, lang, got, tc.details)
+			}
+		}
+	}
+}
+
+// balanced reports whether the brackets of src are balanced, ignoring those of
+// comments and string literals.
+func balanced(src string) bool {
+	var stack []rune
+	pairs := map[rune]rune{')': '(', ']': '[', '}': '{'}
+	for _, line := range strings.Split(src, "\n") {
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "*") || strings.HasPrefix(trimmed, "/*") {
+			continue
+		}
+		quoted, escaped := false, false
+		for _, r := range line {
+			switch {
+			case escaped:
+				escaped = false
+			case quoted && r == '\\':
+				escaped = true
+			case r == '"':
+				quoted = !quoted
+			case quoted:
+			case r == '(' || r == '[' || r == '{':
+				stack = append(stack, r)
+			case r == ')' || r == ']' || r == '}':
+				if len(stack) == 0 || stack[len(stack)-1] != pairs[r] {
+					return false
+				}
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return len(stack) == 0
+}
+
+func TestGenerateTargets(t *testing.T) {
+	javaLifecycle := []string{"public void cancel(String id)", "onCatalystInstanceDestroy()"}
+	javaStreams := []string{"public void startUploadItems(", `case "complete":`}
+	kotlinLifecycle := []string{"fun cancel(id: String)", "onCatalystInstanceDestroy()"}
+	kotlinStreams := []string{"fun startUploadItems(", `"complete" ->`}
+	for _, tc := range []struct {
+		name string
+		opts Options
+		// example and other are the files holding the modules of
+		// ExampleService and OtherService.
+		example, other string
+		// lifecycle must be in both, streams in the ExampleService one.
+		lifecycle, streams []string
+	}{
+		{
+			name:      "java",
+			opts:      Options{Lang: "java"},
+			example:   "ExampleServiceModule.java",
+			other:     "OtherServiceModule.java",
+			lifecycle: javaLifecycle,
+			streams:   javaStreams,
+		},
+		{
+			name:      "java lite",
+			opts:      Options{Lang: "java", Runtime: "lite"},
+			example:   "ExampleServiceModule.java",
+			other:     "OtherServiceModule.java",
+			lifecycle: javaLifecycle,
+			streams:   javaStreams,
+		},
+		{
+			name:      "java turbomodule",
+			opts:      Options{Lang: "java", TurboModule: true, SpecPackage: "com.example.specs"},
+			example:   "ExampleServiceModule.java",
+			other:     "OtherServiceModule.java",
+			lifecycle: javaLifecycle,
+			streams:   append([]string{"extends NativeExampleServiceSpec"}, javaStreams...),
+		},
+		{
+			name:      "kotlin",
+			opts:      Options{Lang: "kotlin"},
+			example:   "ExampleModule.kt",
+			other:     "ExampleModule.kt",
+			lifecycle: kotlinLifecycle,
+			streams:   kotlinStreams,
+		},
+		{
+			name:      "kotlin lite",
+			opts:      Options{Lang: "kotlin", Runtime: "lite"},
+			example:   "ExampleModule.kt",
+			other:     "ExampleModule.kt",
+			lifecycle: kotlinLifecycle,
+			streams:   kotlinStreams,
+		},
+		{
+			name:      "kotlin turbomodule",
+			opts:      Options{Lang: "kotlin", TurboModule: true, SpecPackage: "com.example.specs"},
+			example:   "ExampleModule.kt",
+			other:     "ExampleModule.kt",
+			lifecycle: kotlinLifecycle,
+			streams:   append([]string{"NativeExampleServiceSpec(reactContext)"}, kotlinStreams...),
+		},
+		{
+			name:      "objc",
+			opts:      Options{Target: "objc"},
+			example:   "ExampleModule.m",
+			other:     "ExampleModule.m",
+			lifecycle: []string{"RCT_EXPORT_METHOD(cancel:", "- (void)invalidate"},
+			streams:   []string{"RCT_EXPORT_METHOD(startUploadItems:", `[action isEqualToString:@"complete"]`},
+		},
+		{
+			name:      "swift",
+			opts:      Options{Target: "swift"},
+			example:   "ExampleModule.swift",
+			other:     "ExampleModule.swift",
+			lifecycle: []string{"func cancel(_ id: String)", "override func invalidate()"},
+			streams:   []string{"func startUploadItems(", `case "complete":`},
+		},
+	} {
+		files := generateTest(t, tc.opts)
+		for name, content := range files {
+			if !balanced(content) {
+				t.Errorf("%s: %s has unbalanced brackets", tc.name, name)
+			}
+			// Java requires public classes to be declared in a file of
+			// their name.
+			if strings.HasSuffix(name, ".java") {
+				want := "class " + strings.TrimSuffix(name, ".java") + " "
+				if !strings.Contains(content, "public "+want) && !strings.Contains(content, "public final "+want) && !strings.Contains(content, "\n"+want) {
+					t.Errorf("%s: %s does not declare %s", tc.name, name, want)
+				}
+			}
+		}
+		for _, module := range []string{tc.example, tc.other} {
+			content, ok := files[module]
+			if !ok {
+				t.Errorf("%s: %s not generated, got %v", tc.name, module, keys(files))
+				continue
+			}
+			paths := tc.lifecycle
+			if module == tc.example {
+				paths = append(paths, tc.streams...)
+			}
+			for _, path := range paths {
+				if !strings.Contains(content, path) {
+					t.Errorf("%s: %s lacks %q", tc.name, module, path)
+				}
+			}
+		}
+	}
+}
+
+func TestTurboModulePackage(t *testing.T) {
+	for _, lang := range []string{"java", "kotlin"} {
+		files := generateTest(t, Options{Lang: lang, TurboModule: true, SpecPackage: "com.example.specs"})
+		pkg := files["GeneratedGrpcPackage.java"]
+		for _, want := range []string{"extends BaseReactPackage", "getReactModuleInfoProvider()", `case "ExampleService":`, `case "OtherService":`, `case "GrpcConfig":`} {
+			if !strings.Contains(pkg, want) {
+				t.Errorf("lang=%s: GeneratedGrpcPackage.java lacks %q", lang, want)
+			}
+		}
+		if config := files["GrpcConfigModule.java"]; !strings.Contains(config, "extends NativeGrpcConfigSpec") {
+			t.Errorf("lang=%s: GrpcConfigModule.java does not extend NativeGrpcConfigSpec", lang)
+		}
+	}
+}
+
+// keys returns the names of files.
+func keys(files map[string]string) []string {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/grpc-ecosystem/grpc-gateway/protoc-gen-grpc-gateway/descriptor"
+	"github.com/valyala/fasttemplate"
+)
+
+const (
+	kotlinHeader = `// Code generated by protoc-gen-react
+// DO NOT EDIT!
+package {{packageName}}
+
+import com.facebook.react.bridge.*
+import com.facebook.react.modules.core.DeviceEventManagerModule
+import kotlinx.coroutines.*
+import kotlinx.coroutines.channels.Channel
+import kotlinx.coroutines.flow.consumeAsFlow
+import java.util.UUID
+import java.util.concurrent.ConcurrentHashMap
+
+import {{protoPackages}}.*
+`
+	kotlinServiceCancel = `
+    private val unaryCalls = ConcurrentHashMap<String, Job>()
+    private val streamCalls = ConcurrentHashMap<String, Job>()
+
+    /**
+     * Cancels the call started under id. Unary calls, made with a requestId, reject with
+     * CANCELLED and streams end with a {done: true, cancelled: true} event.
+     */
+    @ReactMethod
+    {{override}}fun cancel(id: String) {
+        val unary = unaryCalls.remove(id)
+        if (unary != null) {
+            unary.cancel()
+            return
+        }
+        val call = streamCalls.remove(id) ?: return
+        call.cancel()
+        val event = Arguments.createMap()
+        event.putBoolean("done", true)
+        event.putBoolean("cancelled", true)
+        reactApplicationContext.getJSModule(DeviceEventManagerModule.RCTDeviceEventEmitter::class.java)
+            .emit(id, event)
+    }
+`
+	kotlinServiceTop = `
+class {{serviceName}}Module(reactContext: ReactApplicationContext, private val engine: GrpcEngine) :
+    {{baseClass}}(reactContext) {
+
+    private class Stream<T, C : Job>(
+        val requests: Channel<T>,
+        val call: C,
+        val capture: GrpcCallOptions.Capture,
+        val withMetadata: Boolean
+    ) {
+        // halfClosed is set by "complete", streams stay registered until the server ends them.
+        var halfClosed = false
+    }
+
+    private val scope = CoroutineScope(SupervisorJob() + Dispatchers.IO)
+{{streams}}{{cancel}}{{registry}}
+    /**
+     * @return the name of this module. This will be the name used to {@code require()} this module
+     * from javascript.
+     */
+    override fun getName() = "{{serviceName}}"
+
+    override fun {{constantsMethod}}(): Map<String, kotlin.Any> = mapOf("NAME" to {{serviceName}}Grpc.SERVICE_NAME)
+
+    // Cancels every call and stream still open, they all run in the scope.
+    override fun onCatalystInstanceDestroy() {
+        scope.cancel()
+    }
+
+    private fun stub(options: ReadableMap?, capture: GrpcCallOptions.Capture) = GrpcCallOptions.apply(
+        engine.attachHeaders({{serviceName}}GrpcKt.{{serviceName}}CoroutineStub(engine.byServiceName({{serviceName}}Grpc.SERVICE_NAME))),
+        options
+    ).withInterceptors(capture.interceptor())
+
+    private fun emit(eventID: String, done: Boolean, data: WritableMap?, error: Throwable?, trailers: WritableMap? = null) {
+        val event = Arguments.createMap()
+        event.putBoolean("done", done)
+        if (data != null) {
+            event.putMap("data", data)
+        }
+        if (error != null) {
+            GrpcErrors.putError(event, error)
+        }
+        if (trailers != null) {
+            event.putMap("trailers", trailers)
+        }
+        reactApplicationContext.getJSModule(DeviceEventManagerModule.RCTDeviceEventEmitter::class.java)
+            .emit(eventID, event)
+    }
+`
+)
+
+// kotlinResolve returns the statements resolving the promise of a call with
+// the response returned by expr, at the given indentation.
+func (g *generator) kotlinResolve(response *descriptor.Message, expr, withMetadata, capture string, indent int) string {
+	result := "promise.resolve(if (" + withMetadata + ") " + capture + ".wrap(response) else response)"
+	if isEmptyMessage(response) {
+		return expr + "\n" + strings.Repeat(" ", indent) + "val response: WritableMap? = null\n" + strings.Repeat(" ", indent) + result
+	}
+	return "val response = " + g.javaToMap(response) + "(" + expr + ")\n" + strings.Repeat(" ", indent) + result
+}
+
+func (g *generator) generateKotlinMethod(m *descriptor.Method, buf io.Writer) error {
+	params := map[string]interface{}{
+		"methodName":    ToJsonName(m.GetName()),
+		"bigName":       strings.Title(ToJsonName(m.GetName())),
+		"requestType":   g.javaMessageName(m.RequestType),
+		"responseType":  g.javaMessageName(m.ResponseType),
+		"fromMap":       g.javaFromMap(m.RequestType),
+		"toMap":         g.javaToMap(m.ResponseType),
+		"resolve":       g.kotlinResolve(m.ResponseType, "stub(options, capture)."+ToJsonName(m.GetName())+"(request)", "withMetadata", "capture", 16),
+		"resolveStream": g.kotlinResolve(m.ResponseType, "stream.call.await()", "stream.withMetadata", "stream.capture", 24),
+		"override":      "",
+	}
+	if g.TurboModule {
+		params["override"] = "override "
+	}
+	var temp string
+	switch {
+	case !m.GetClientStreaming() && !m.GetServerStreaming():
+		temp = `
+    @ReactMethod
+    {{override}}fun {{methodName}}(input: ReadableMap, options: ReadableMap?, promise: Promise) {
+        val request = try {
+            {{fromMap}}(input)
+        } catch (e: IllegalArgumentException) {
+            GrpcErrors.rejectInvalid(promise, e.message ?: "invalid message")
+            return
+        }
+        val capture = GrpcCallOptions.Capture()
+        val withMetadata = GrpcCallOptions.withMetadata(options)
+        val requestId = GrpcCallOptions.requestId(options)
+        val call = scope.launch(start = CoroutineStart.LAZY) {
+            try {
+                {{resolve}}
+            } catch (e: CancellationException) {
+                GrpcErrors.reject(promise, e)
+                throw e
+            } catch (t: Throwable) {
+                GrpcErrors.reject(promise, t)
+            } finally {
+                requestId?.let { unaryCalls.remove(it, coroutineContext.job) }
+            }
+        }
+        if (requestId != null && unaryCalls.putIfAbsent(requestId, call) != null) {
+            call.cancel()
+            GrpcErrors.rejectInFlight(promise, requestId)
+            return
+        }
+        call.start()
+    }
+`
+	case !m.GetClientStreaming() && m.GetServerStreaming():
+		temp = `
+    @ReactMethod
+    {{override}}fun {{methodName}}(input: ReadableMap, options: ReadableMap?, promise: Promise) {
+        val request = try {
+            {{fromMap}}(input)
+        } catch (e: IllegalArgumentException) {
+            GrpcErrors.rejectInvalid(promise, e.message ?: "invalid message")
+            return
+        }
+        val eventID = UUID.randomUUID().toString()
+        val capture = GrpcCallOptions.Capture()
+        // Registered before it starts, the call unregisters itself when it ends.
+        // Calls cancelled from javascript already sent their last event.
+        val call = scope.launch(start = CoroutineStart.LAZY) {
+            try {
+                stub(options, capture).{{methodName}}(request).collect { emit(eventID, false, {{toMap}}(it), null) }
+                if (streamCalls.remove(eventID) != null) {
+                    emit(eventID, true, null, null, capture.trailers())
+                }
+            } catch (e: CancellationException) {
+                throw e
+            } catch (t: Throwable) {
+                if (streamCalls.remove(eventID) != null) {
+                    emit(eventID, true, null, t, capture.trailers())
+                }
+            } finally {
+                streamCalls.remove(eventID)
+            }
+        }
+        streamCalls[eventID] = call
+        call.start()
+        promise.resolve(eventID)
+    }
+`
+	case m.GetClientStreaming() && !m.GetServerStreaming():
+		temp = `
+    @ReactMethod
+    {{override}}fun start{{bigName}}(options: ReadableMap?, promise: Promise) {
+        val id = UUID.randomUUID().toString()
+        val requests = Channel<{{requestType}}>(Channel.UNLIMITED)
+        val capture = GrpcCallOptions.Capture()
+        val call = scope.async { stub(options, capture).{{methodName}}(requests.consumeAsFlow()) }
+        val stream = Stream(requests, call, capture, GrpcCallOptions.withMetadata(options))
+        {{methodName}}Streams[id] = stream
+        call.invokeOnCompletion { {{methodName}}Streams.remove(id, stream) }
+        promise.resolve(id)
+    }
+
+    /**
+     * Sends input to the stream id, or half-closes it after sending input, if any, with "complete",
+     * or cancels it with "error". "complete" resolves the response, the other actions resolve once
+     * the message is queued and reject once the stream is closed, or half-closed but for "error".
+     */
+    @ReactMethod
+    {{override}}fun {{methodName}}(id: String, action: String, input: ReadableMap?, promise: Promise) {
+        val stream = if (action == "error") {{methodName}}Streams.remove(id) else {{methodName}}Streams[id]
+        if (stream == null) {
+            promise.reject("not_found", "stream $id is not open")
+            return
+        }
+        if (stream.halfClosed && action != "error") {
+            promise.reject("half_closed", "stream $id is half-closed")
+            return
+        }
+        val request = try {
+            input?.takeIf { action != "error" }?.let { {{fromMap}}(it) }
+        } catch (e: IllegalArgumentException) {
+            GrpcErrors.rejectInvalid(promise, e.message ?: "invalid message")
+            return
+        }
+        when (action) {
+            "complete" -> {
+                stream.halfClosed = true
+                request?.let { stream.requests.trySend(it) }
+                stream.requests.close()
+                scope.launch {
+                    try {
+                        {{resolveStream}}
+                    } catch (t: Throwable) {
+                        GrpcErrors.reject(promise, t)
+                    }
+                }
+            }
+            "error" -> {
+                stream.call.cancel()
+                promise.resolve(null)
+            }
+            else -> {
+                if (request == null) {
+                    GrpcErrors.rejectInvalid(promise, "stream $id needs a message to send")
+                    return
+                }
+                stream.requests.trySend(request)
+                promise.resolve(null)
+            }
+        }
+    }
+`
+	default:
+		temp = `
+    @ReactMethod
+    {{override}}fun start{{bigName}}(options: ReadableMap?, promise: Promise) {
+        val id = UUID.randomUUID().toString()
+        val requests = Channel<{{requestType}}>(Channel.UNLIMITED)
+        val capture = GrpcCallOptions.Capture()
+        val call = scope.launch(start = CoroutineStart.LAZY) {
+            try {
+                stub(options, capture).{{methodName}}(requests.consumeAsFlow()).collect { emit(id, false, {{toMap}}(it), null) }
+                emit(id, true, null, null, capture.trailers())
+            } catch (e: CancellationException) {
+                throw e
+            } catch (t: Throwable) {
+                emit(id, true, null, t, capture.trailers())
+            } finally {
+                {{methodName}}Streams.remove(id)
+            }
+        }
+        {{methodName}}Streams[id] = Stream(requests, call, capture, false)
+        call.start()
+        promise.resolve(id)
+    }
+
+    /**
+     * Sends input to the stream id, or half-closes it after sending input, if any, with "complete",
+     * or cancels it with the code and message of input with "error". The promise resolves once the
+     * message is queued and rejects once the stream is closed, or half-closed but for "error".
+     */
+    @ReactMethod
+    {{override}}fun {{methodName}}(id: String, action: String, input: ReadableMap?, promise: Promise) {
+        val stream = if (action == "error") {{methodName}}Streams.remove(id) else {{methodName}}Streams[id]
+        if (stream == null) {
+            promise.reject("not_found", "stream $id is not open")
+            return
+        }
+        if (stream.halfClosed && action != "error") {
+            promise.reject("half_closed", "stream $id is half-closed")
+            return
+        }
+        val request = try {
+            input?.takeIf { action != "error" }?.let { {{fromMap}}(it) }
+        } catch (e: IllegalArgumentException) {
+            GrpcErrors.rejectInvalid(promise, e.message ?: "invalid message")
+            return
+        }
+        when (action) {
+            "complete" -> {
+                stream.halfClosed = true
+                request?.let { stream.requests.trySend(it) }
+                stream.requests.close()
+            }
+            "error" -> {
+                stream.call.cancel()
+                emit(id, true, null, GrpcErrors.fromJS(input), stream.capture.trailers())
+            }
+            else -> if (stream.requests.trySend(request ?: {{requestType}}.getDefaultInstance()).isFailure) {
+                promise.reject("not_found", "stream $id is not open")
+                return
+            }
+        }
+        promise.resolve(null)
+    }
+`
+	}
+	_, err := fasttemplate.Execute(temp, "{{", "}}", buf, params)
+	return err
+}
+
+// kotlinCancel returns the cancel method of svc, if it has calls to cancel.
+func (g *generator) kotlinCancel(svc *descriptor.Service) string {
+	if !hasCancellable(svc) {
+		return ""
+	}
+	override := ""
+	if g.TurboModule {
+		override = "override "
+	}
+	return strings.Replace(kotlinServiceCancel, "{{override}}", override, 1)
+}
+
+// kotlinStreamFields declares the open calls of the streaming methods of svc.
+// Streams leave them when the server ends them, from the coroutines of the
+// calls.
+func (g *generator) kotlinStreamFields(svc *descriptor.Service) string {
+	var buf bytes.Buffer
+	for _, m := range svc.Methods {
+		if !m.GetClientStreaming() {
+			continue
+		}
+		call := fmt.Sprintf("Deferred<%s>", g.javaMessageName(m.ResponseType))
+		if m.GetServerStreaming() {
+			call = "Job"
+		}
+		fmt.Fprintf(&buf, "    private val %sStreams = ConcurrentHashMap<String, Stream<%s, %s>>()\n", ToJsonName(m.GetName()),
+			g.javaMessageName(m.RequestType), call)
+	}
+	return buf.String()
+}
+
+// kotlinBiStreamRegistry returns the method counting the open bidirectional
+// streams of svc, which are cancelled with the scope of the module.
+func (g *generator) kotlinBiStreamRegistry(svc *descriptor.Service) string {
+	var sizes []string
+	for _, m := range svc.Methods {
+		if m.GetClientStreaming() && m.GetServerStreaming() {
+			sizes = append(sizes, ToJsonName(m.GetName())+"Streams.size")
+		}
+	}
+	if len(sizes) == 0 {
+		return ""
+	}
+	override := ""
+	if g.TurboModule {
+		override = "override "
+	}
+	return fmt.Sprintf(`
+    /**
+     * Resolves the number of open bidirectional streams, for diagnostics.
+     */
+    @ReactMethod
+    %sfun activeStreamCount(promise: Promise) {
+        promise.resolve(%s)
+    }
+`, override, strings.Join(sizes, " + "))
+}
+
+// generateKotlinFile returns the modules of the services of file, which
+// convert messages with the java converters classes.
+func (g *generator) generateKotlinFile(file *descriptor.File, classes []*javaConverters) (string, error) {
+	var buf bytes.Buffer
+	pn := g.moduleJavaPackage(file)
+	fasttemplate.Execute(kotlinHeader, "{{", "}}", &buf, map[string]interface{}{
+		"packageName":   pn,
+		"protoPackages": javaPackageOf(file),
+	})
+	g.javaImports = map[string]bool{pn: true, javaPackageOf(file): true}
+	g.writeEngineImports(pn, "", &buf)
+	if g.TurboModule {
+		fmt.Fprintf(&buf, "import %s.*\n", g.SpecPackage)
+	}
+	for _, c := range classes {
+		if c.javaPackage != pn {
+			fmt.Fprintf(&buf, "import %s.%s\n", c.javaPackage, c.name)
+		}
+	}
+	for _, svc := range file.Services {
+		glog.V(1).Infof("Service Name %s", svc.GetName())
+		fasttemplate.Execute(kotlinServiceTop, "{{", "}}", &buf, map[string]interface{}{
+			"serviceName":     svc.GetName(),
+			"baseClass":       g.moduleBaseClass(svc),
+			"constantsMethod": g.constantsMethod(),
+			"streams":         g.kotlinStreamFields(svc),
+			"cancel":          g.kotlinCancel(svc),
+			"registry":        g.kotlinBiStreamRegistry(svc),
+		})
+		for _, m := range svc.Methods {
+			if err := g.generateKotlinMethod(m, &buf); err != nil {
+				return "", err
+			}
+		}
+		buf.WriteString("}\n")
+	}
+	return buf.String(), nil
+}
+
+// generateKotlin emits the Android modules in Kotlin, on top of the grpc-kotlin
+// coroutine stubs. Every call runs in a scope owned by the module, which is
+// cancelled with the React instance.
+func (g *generator) generateKotlin(targets []*descriptor.File, classes []*javaConverters) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	for _, file := range targets {
+		str, err := g.generateKotlinFile(file, classes)
+		if err != nil {
+			return nil, err
+		}
+		name := file.GetName()
+		output := fmt.Sprintf("%sModule.kt", ToFileName(strings.TrimSuffix(name, filepath.Ext(name))))
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(str),
+		})
+		glog.V(1).Infof("Will emit %s", output)
+	}
+	return files, nil
+}
@@ -14,8 +14,17 @@ import (
 )
 
 var (
-	file = flag.String("file", "stdin", "Where to load data from")
+	file        = flag.String("file", "stdin", "Where to load data from")
 	packageName = flag.String("package", "", "Java package name, overrides default java_package option")
+	target      = flag.String("target", "android", "Native platform to generate modules for: android, objc or swift")
+	lang        = flag.String("lang", "java", "Language of the android modules: java or kotlin")
+	turboModule = flag.Bool("turbomodule", false, "Implement the React Native codegen specs of the services")
+	specPackage = flag.String("spec_package", "com.facebook.fbreact.specs", "Java package of the codegen specs, codegenConfig.android.javaPackageName")
+	int64Mode   = flag.String("int64", "string", "How 64-bit integers are passed to javascript: string or number")
+	timestamp   = flag.String("timestamp", "iso", "How timestamps are passed to javascript: iso or ms")
+	bytesMode   = flag.String("bytes", "base64", "How bytes are passed to javascript: base64 or utf8")
+	runtime     = flag.String("runtime", "full", "Protobuf runtime of the android modules: full or lite")
+	enginePkg   = flag.String("engine_package", "", "Java package of GrpcEngine and GeneratedGrpcPackage, defaults to the one of the first module")
 )
 
 func parseReq(r io.Reader) (*plugin.CodeGeneratorRequest, error) {
@@ -64,7 +73,18 @@ func main() {
 			}
 		}
 	}
-	g := NewGenerator(reg, *packageName)
+	g := NewGenerator(reg, Options{
+		PackageName:   *packageName,
+		Target:        *target,
+		Lang:          *lang,
+		TurboModule:   *turboModule,
+		SpecPackage:   *specPackage,
+		Int64:         *int64Mode,
+		Timestamp:     *timestamp,
+		Bytes:         *bytesMode,
+		Runtime:       *runtime,
+		EnginePackage: *enginePkg,
+	})
 	if err := reg.Load(req); err != nil {
 		glog.Errorf("genangular emit error: %v", err)
 		emitError(err)
@@ -77,9 +97,7 @@ func main() {
 		if err != nil {
 			glog.Fatal(err)
 		}
-		if len(f.GetService()) > 0 {
-			targets = append(targets, f)
-		}
+		targets = append(targets, f)
 	}
 
 	out, err := g.Generate(targets)
@@ -0,0 +1,1472 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	gdescriptor "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/grpc-ecosystem/grpc-gateway/protoc-gen-grpc-gateway/descriptor"
+	"github.com/valyala/fasttemplate"
+)
+
+const (
+	objcHeader = `// Code generated by protoc-gen-react
+// DO NOT EDIT!
+#import <Foundation/Foundation.h>
+#import <React/RCTBridgeModule.h>
+#import <React/RCTInvalidating.h>
+`
+	objcServiceHeader = `
+@interface {{serviceName}}Module : NSObject <{{protocols}}>
+@end
+`
+	objcImplHeader = `// Code generated by protoc-gen-react
+// DO NOT EDIT!
+#import "{{header}}"
+
+#import <React/RCTBridge.h>
+#import <ProtoRPC/ProtoRPC.h>
+#import <RxLibrary/GRXBufferedPipe.h>
+
+#import "GrpcEngine.h"
+`
+	objcHelpers = `
+static id RNGrpcValue(NSDictionary *in, NSString *key) {
+    id value = in[key];
+    return value == [NSNull null] ? nil : value;
+}
+
+static uint64_t RNGrpcUInt64(id value) {
+    return strtoull([[value description] UTF8String], NULL, 10);
+}
+
+// RNGrpcApplyCallOptions applies the options javascript passes to a call, or
+// returns an UNIMPLEMENTED error for those it cannot apply. The values of -bin
+// metadata are base64. gRPC-ObjC has no per-call compression, it is set by
+// host with +[GRPCCall setDefaultCompressMethod:forhost:].
+static NSError *RNGrpcApplyCallOptions(GRPCProtoCall *call, NSDictionary *options) {
+    if (RNGrpcValue(options, @"compression") != nil) {
+        NSString *message = @"per-call compression is not supported by gRPC-ObjC, set it by host with +[GRPCCall setDefaultCompressMethod:forhost:]";
+        return [NSError errorWithDomain:kGRPCErrorDomain code:GRPCErrorCodeUnimplemented userInfo:@{NSLocalizedDescriptionKey: message}];
+    }
+    id deadline = RNGrpcValue(options, @"deadlineMs");
+    if (deadline != nil) {
+        call.timeout = [deadline doubleValue] / 1000;
+    }
+    NSDictionary *metadata = RNGrpcValue(options, @"metadata");
+    for (NSString *key in metadata) {
+        if ([key hasSuffix:@"-bin"]) {
+            call.requestHeaders[key] = [[NSData alloc] initWithBase64EncodedString:metadata[key] options:0];
+        } else {
+            call.requestHeaders[key] = metadata[key];
+        }
+    }
+    return nil;
+}
+
+static NSDictionary *RNGrpcMetadataToJS(NSDictionary *metadata) {
+    NSMutableDictionary *out = [NSMutableDictionary dictionary];
+    for (NSString *key in metadata) {
+        id value = metadata[key];
+        out[key] = [value isKindOfClass:[NSData class]] ? [value base64EncodedStringWithOptions:0] : value;
+    }
+    return out;
+}
+
+// RNGrpcWithMetadata returns what calls made withMetadata resolve with.
+static NSDictionary *RNGrpcWithMetadata(GRPCProtoCall *call, id response) {
+    return @{
+        @"response": response ?: [NSNull null],
+        @"headers": RNGrpcMetadataToJS(call.responseHeaders),
+        @"trailers": RNGrpcMetadataToJS(call.responseTrailers),
+    };
+}
+`
+	objcNanosHelpers = `
+static NSString *RNGrpcFormatNanos(int32_t nanos) {
+    if (nanos == 0) {
+        return @"";
+    } else if (nanos % 1000000 == 0) {
+        return [NSString stringWithFormat:@".%03d", nanos / 1000000];
+    } else if (nanos % 1000 == 0) {
+        return [NSString stringWithFormat:@".%06d", nanos / 1000];
+    }
+    return [NSString stringWithFormat:@".%09d", nanos];
+}
+
+static int32_t RNGrpcParseNanos(const char **cursor) {
+    int32_t nanos = 0;
+    int digits = 0;
+    if (**cursor != '.') {
+        return 0;
+    }
+    for ((*cursor)++; **cursor >= '0' && **cursor <= '9'; (*cursor)++, digits++) {
+        if (digits < 9) {
+            nanos = nanos * 10 + (**cursor - '0');
+        }
+    }
+    for (; digits < 9; digits++) {
+        nanos *= 10;
+    }
+    return nanos;
+}
+`
+	objcTimestampHelpers = `
+// RNGrpcTimestampFromString returns nil for a value that is not an RFC 3339
+// string.
+static GPBTimestamp *RNGrpcTimestampFromString(id value) {
+    if (![value isKindOfClass:[NSString class]]) {
+        return nil;
+    }
+    const char *cursor = [value UTF8String];
+    struct tm tm = {0};
+    int consumed = 0;
+    if (sscanf(cursor, "%4d-%2d-%2dT%2d:%2d:%2d%n", &tm.tm_year, &tm.tm_mon, &tm.tm_mday, &tm.tm_hour, &tm.tm_min, &tm.tm_sec, &consumed) != 6) {
+        return nil;
+    }
+    tm.tm_year -= 1900;
+    tm.tm_mon -= 1;
+    cursor += consumed;
+    GPBTimestamp *msg = [GPBTimestamp message];
+    msg.seconds = timegm(&tm);
+    msg.nanos = RNGrpcParseNanos(&cursor);
+    if (*cursor == 'Z' || *cursor == 'z') {
+        cursor++;
+    } else if (*cursor == '+' || *cursor == '-') {
+        int hours = 0, minutes = 0;
+        if (sscanf(cursor + 1, "%2d:%2d%n", &hours, &minutes, &consumed) != 2) {
+            return nil;
+        }
+        int offset = hours * 3600 + minutes * 60;
+        msg.seconds -= *cursor == '+' ? offset : -offset;
+        cursor += 1 + consumed;
+    } else {
+        return nil;
+    }
+    return *cursor == '\0' ? msg : nil;
+}
+
+static NSString *RNGrpcTimestampToString(GPBTimestamp *msg) {
+    time_t seconds = (time_t)msg.seconds;
+    struct tm tm;
+    char date[32];
+    gmtime_r(&seconds, &tm);
+    strftime(date, sizeof(date), "%Y-%m-%dT%H:%M:%S", &tm);
+    return [NSString stringWithFormat:@"%s%@Z", date, RNGrpcFormatNanos(msg.nanos)];
+}
+`
+	objcTimestampMillisHelpers = `
+static GPBTimestamp *RNGrpcTimestampFromMillis(id value) {
+    double millis = [value doubleValue];
+    GPBTimestamp *msg = [GPBTimestamp message];
+    msg.seconds = (int64_t)floor(millis / 1000);
+    msg.nanos = (int32_t)MIN(llround((millis - msg.seconds * 1000.0) * 1000000), 999999999);
+    return msg;
+}
+
+static NSNumber *RNGrpcTimestampToMillis(GPBTimestamp *msg) {
+    return @(msg.seconds * 1000.0 + msg.nanos / 1000000.0);
+}
+`
+	objcDurationHelpers = `
+// RNGrpcDurationFromString returns nil for a value that is not a number of
+// seconds suffixed with "s".
+static GPBDuration *RNGrpcDurationFromString(id value) {
+    if (![value isKindOfClass:[NSString class]]) {
+        return nil;
+    }
+    const char *cursor = [value UTF8String];
+    int sign = 1;
+    if (*cursor == '-') {
+        sign = -1;
+        cursor++;
+    }
+    char *end;
+    GPBDuration *msg = [GPBDuration message];
+    msg.seconds = sign * strtoll(cursor, &end, 10);
+    if (end == cursor) {
+        return nil;
+    }
+    cursor = end;
+    msg.nanos = sign * RNGrpcParseNanos(&cursor);
+    return strcmp(cursor, "s") == 0 ? msg : nil;
+}
+
+static NSString *RNGrpcDurationToString(GPBDuration *msg) {
+    BOOL negative = msg.seconds < 0 || msg.nanos < 0;
+    return [NSString stringWithFormat:@"%@%lld%@s", negative ? @"-" : @"", llabs(msg.seconds), RNGrpcFormatNanos(abs(msg.nanos))];
+}
+`
+	objcStructHelpers = `
+static GPBValue *RNGrpcValueFromJS(id value);
+static id RNGrpcValueToJS(GPBValue *msg);
+
+static GPBStruct *RNGrpcStructFromJS(NSDictionary *value) {
+    GPBStruct *msg = [GPBStruct message];
+    for (NSString *key in value) {
+        msg.fields[key] = RNGrpcValueFromJS(value[key]);
+    }
+    return msg;
+}
+
+static NSDictionary *RNGrpcStructToJS(GPBStruct *msg) {
+    NSMutableDictionary *out = [NSMutableDictionary dictionary];
+    for (NSString *key in msg.fields) {
+        out[key] = RNGrpcValueToJS(msg.fields[key]);
+    }
+    return out;
+}
+
+static GPBListValue *RNGrpcListValueFromJS(NSArray *value) {
+    GPBListValue *msg = [GPBListValue message];
+    for (id item in value) {
+        [msg.valuesArray addObject:RNGrpcValueFromJS(item)];
+    }
+    return msg;
+}
+
+static NSArray *RNGrpcListValueToJS(GPBListValue *msg) {
+    NSMutableArray *out = [NSMutableArray array];
+    for (GPBValue *item in msg.valuesArray) {
+        [out addObject:RNGrpcValueToJS(item)];
+    }
+    return out;
+}
+
+static GPBValue *RNGrpcValueFromJS(id value) {
+    GPBValue *msg = [GPBValue message];
+    if ([value isKindOfClass:[NSDictionary class]]) {
+        msg.structValue = RNGrpcStructFromJS(value);
+    } else if ([value isKindOfClass:[NSArray class]]) {
+        msg.listValue = RNGrpcListValueFromJS(value);
+    } else if ([value isKindOfClass:[NSString class]]) {
+        msg.stringValue = value;
+    } else if (value == (id)kCFBooleanTrue || value == (id)kCFBooleanFalse) {
+        msg.boolValue = [value boolValue];
+    } else if ([value isKindOfClass:[NSNumber class]]) {
+        msg.numberValue = [value doubleValue];
+    } else {
+        msg.nullValue = GPBNullValue_NullValue;
+    }
+    return msg;
+}
+
+static id RNGrpcValueToJS(GPBValue *msg) {
+    switch (msg.kindOneOfCase) {
+        case GPBValue_Kind_OneOfCase_BoolValue:
+            return @(msg.boolValue);
+        case GPBValue_Kind_OneOfCase_NumberValue:
+            return @(msg.numberValue);
+        case GPBValue_Kind_OneOfCase_StringValue:
+            return msg.stringValue;
+        case GPBValue_Kind_OneOfCase_StructValue:
+            return RNGrpcStructToJS(msg.structValue);
+        case GPBValue_Kind_OneOfCase_ListValue:
+            return RNGrpcListValueToJS(msg.listValue);
+        default:
+            return [NSNull null];
+    }
+}
+`
+	objcWrapperHelpers = `
+static GPB{{name}} *RNGrpc{{name}}FromJS(id value) {
+    GPB{{name}} *msg = [GPB{{name}} message];
+    msg.value = {{fromJS}};
+    return msg;
+}
+
+static id RNGrpc{{name}}ToJS(GPB{{name}} *msg) {
+    return {{toJS}};
+}
+`
+	objcFieldMaskHelpers = `
+static GPBFieldMask *RNGrpcFieldMaskFromJS(id value) {
+    if (![value isKindOfClass:[NSString class]]) {
+        return nil;
+    }
+    GPBFieldMask *msg = [GPBFieldMask message];
+    for (NSString *path in [value componentsSeparatedByString:@","]) {
+        if (path.length == 0) {
+            continue;
+        }
+        NSMutableString *snake = [NSMutableString string];
+        for (NSUInteger i = 0; i < path.length; i++) {
+            unichar c = [path characterAtIndex:i];
+            if (c >= 'A' && c <= 'Z') {
+                [snake appendFormat:@"_%C", (unichar)(c - 'A' + 'a')];
+            } else {
+                [snake appendFormat:@"%C", c];
+            }
+        }
+        [msg.pathsArray addObject:snake];
+    }
+    return msg;
+}
+
+static NSString *RNGrpcFieldMaskToJS(GPBFieldMask *msg) {
+    NSMutableArray *paths = [NSMutableArray array];
+    for (NSString *path in msg.pathsArray) {
+        NSMutableString *camel = [NSMutableString string];
+        BOOL upper = NO;
+        for (NSUInteger i = 0; i < path.length; i++) {
+            unichar c = [path characterAtIndex:i];
+            if (c == '_') {
+                upper = YES;
+                continue;
+            }
+            if (upper && c >= 'a' && c <= 'z') {
+                c = c - 'a' + 'A';
+            }
+            [camel appendFormat:@"%C", c];
+            upper = NO;
+        }
+        [paths addObject:camel];
+    }
+    return [paths componentsJoinedByString:@","];
+}
+`
+	objcStreamClass = `
+@interface {{serviceName}}ModuleStream : NSObject
+@property(nonatomic, copy) NSString *streamID;
+@property(nonatomic, strong) GRXBufferedPipe *pipe;
+@property(nonatomic, strong) GRPCProtoCall *call;
+@property(nonatomic) BOOL withMetadata;
+@property(atomic, strong) NSError *cancelError;
+// Set by "complete", streams stay registered until the server ends them.
+@property(nonatomic) BOOL halfClosed;
+@end
+
+@implementation {{serviceName}}ModuleStream {
+    id _response;
+    NSError *_error;
+    BOOL _finished;
+    RCTPromiseResolveBlock _resolve;
+    RCTPromiseRejectBlock _reject;
+}
+
+- (instancetype)init
+{
+    if ((self = [super init])) {
+        _streamID = [[NSUUID UUID] UUIDString];
+        _pipe = [GRXBufferedPipe pipe];
+    }
+    return self;
+}
+
+- (void)finishWithResponse:(id)response error:(NSError *)error
+{
+    @synchronized (self) {
+        _response = response;
+        _error = error;
+        _finished = YES;
+        [self settle];
+    }
+}
+
+- (void)awaitWithResolver:(RCTPromiseResolveBlock)resolve rejecter:(RCTPromiseRejectBlock)reject
+{
+    @synchronized (self) {
+        _resolve = resolve;
+        _reject = reject;
+        [self settle];
+    }
+}
+
+- (void)settle
+{
+    if (!_finished || _resolve == nil) {
+        return;
+    }
+    if (_error != nil) {
+        RNGrpcReject(_reject, _error);
+    } else if (_response == nil) {
+        _reject(@"null", @"response is null", nil);
+    } else {
+        _resolve(_withMetadata ? RNGrpcWithMetadata(_call, _response) : _response);
+    }
+}
+@end
+`
+	objcServiceTop = `
+@implementation {{serviceName}}Module {
+    NSMutableDictionary<NSString *, {{serviceName}}ModuleStream *> *_streams;
+    NSMutableDictionary<NSString *, GRPCProtoCall *> *_calls;
+    NSMutableDictionary<NSString *, GRPCProtoCall *> *_unaryCalls;
+    NSMutableDictionary<NSString *, {{serviceName}}ModuleStream *> *_biStreams;
+}
+
+@synthesize bridge = _bridge;
+
+RCT_EXPORT_MODULE({{serviceName}})
+
++ (BOOL)requiresMainQueueSetup
+{
+    return NO;
+}
+
+- (NSDictionary *)constantsToExport
+{
+    return @{@"NAME": @"{{fullName}}"};
+}
+
+- ({{serviceClass}} *)service
+{
+    return [{{serviceClass}} serviceWithHost:[[GrpcEngine sharedEngine] hostForServiceName:@"{{fullName}}"]];
+}
+
+- (void)sendEvent:(NSString *)eventID done:(BOOL)done data:(NSDictionary *)data error:(NSError *)error trailers:(NSDictionary *)trailers
+{
+    NSMutableDictionary *body = [NSMutableDictionary dictionary];
+    body[@"done"] = @(done);
+    if (data != nil) {
+        body[@"data"] = data;
+    }
+    if (error != nil) {
+        NSDictionary *info = RNGrpcErrorInfo(error);
+        body[@"error"] = error.localizedDescription;
+        body[@"code"] = RNGrpcCodeName(info);
+        body[@"userInfo"] = info;
+    }
+    if (done) {
+        body[@"trailers"] = RNGrpcMetadataToJS(trailers);
+    }
+    [_bridge enqueueJSCall:@"RCTDeviceEventEmitter" method:@"emit" args:@[eventID, body] completion:NULL];
+}
+`
+)
+
+// objcServiceCancel registers the unary calls made with a requestId and the
+// server streaming calls of a module, to be cancelled from javascript. A call
+// is not registered under the id of one in flight.
+const objcServiceCancel = `
+- (BOOL)addCall:(GRPCProtoCall *)call forID:(NSString *)callID unary:(BOOL)unary
+{
+    @synchronized (self) {
+        if (_calls == nil) {
+            _calls = [NSMutableDictionary dictionary];
+            _unaryCalls = [NSMutableDictionary dictionary];
+        }
+        NSMutableDictionary<NSString *, GRPCProtoCall *> *calls = unary ? _unaryCalls : _calls;
+        if (calls[callID] != nil) {
+            return NO;
+        }
+        calls[callID] = call;
+        return YES;
+    }
+}
+
+- (GRPCProtoCall *)removeCall:(NSString *)callID unary:(BOOL)unary
+{
+    @synchronized (self) {
+        NSMutableDictionary<NSString *, GRPCProtoCall *> *calls = unary ? _unaryCalls : _calls;
+        GRPCProtoCall *call = calls[callID];
+        [calls removeObjectForKey:callID];
+        return call;
+    }
+}
+
+// Cancels the call started under eventID. Unary calls, made with a requestId,
+// reject with CANCELLED and streams end with a {done: true, cancelled: true}
+// event.
+RCT_EXPORT_METHOD(cancel:(NSString *)eventID)
+{
+    GRPCProtoCall *call = [self removeCall:eventID unary:YES];
+    if (call != nil) {
+        [call cancel];
+        return;
+    }
+    call = [self removeCall:eventID unary:NO];
+    if (call == nil) {
+        return;
+    }
+    [call cancel];
+    [_bridge enqueueJSCall:@"RCTDeviceEventEmitter" method:@"emit" args:@[eventID, @{@"done": @YES, @"cancelled": @YES}] completion:NULL];
+}
+`
+
+// writeObjCInvalidate writes the invalidate method of svc, which cancels the
+// calls and streams still open.
+func writeObjCInvalidate(svc *descriptor.Service, buf io.Writer) {
+	cancellable, client, bidi := hasCancellable(svc), hasClientStreaming(svc), hasBiStreaming(svc)
+	if !cancellable && !client && !bidi {
+		return
+	}
+	fmt.Fprint(buf, "\n- (void)invalidate\n{\n")
+	if cancellable {
+		fmt.Fprint(buf, "    NSMutableArray<GRPCProtoCall *> *calls = [NSMutableArray array];\n")
+	}
+	if client || bidi {
+		fmt.Fprintf(buf, "    NSMutableArray<%sModuleStream *> *streams = [NSMutableArray array];\n", svc.GetName())
+	}
+	fmt.Fprint(buf, "    @synchronized (self) {\n")
+	if cancellable {
+		fmt.Fprint(buf, `        [calls addObjectsFromArray:_unaryCalls.allValues];
+        [calls addObjectsFromArray:_calls.allValues];
+        [_unaryCalls removeAllObjects];
+        [_calls removeAllObjects];
+`)
+	}
+	if client {
+		fmt.Fprint(buf, "        [streams addObjectsFromArray:_streams.allValues];\n        [_streams removeAllObjects];\n")
+	}
+	if bidi {
+		fmt.Fprint(buf, "        [streams addObjectsFromArray:_biStreams.allValues];\n        [_biStreams removeAllObjects];\n")
+	}
+	fmt.Fprint(buf, "    }\n")
+	if cancellable {
+		fmt.Fprint(buf, "    for (GRPCProtoCall *call in calls) {\n        [call cancel];\n    }\n")
+	}
+	if client || bidi {
+		fmt.Fprintf(buf, "    for (%sModuleStream *stream in streams) {\n        [stream.call cancel];\n    }\n", svc.GetName())
+	}
+	fmt.Fprint(buf, "}\n")
+}
+
+// objcBiStreamRegistry counts the open bidirectional streams of a module.
+// Streams leave the registry from the call's queue when the server ends them,
+// hence the lock.
+const objcBiStreamRegistry = `
+// Resolves the number of open bidirectional streams, for diagnostics.
+RCT_EXPORT_METHOD(activeStreamCount:(RCTPromiseResolveBlock)resolve
+                  rejecter:(RCTPromiseRejectBlock)reject)
+{
+    @synchronized (self) {
+        resolve(@(_biStreams.count));
+    }
+}
+`
+
+// objcScalar describes how the Objective-C protobuf runtime stores a scalar
+// field type, and how it is read from a JS value.
+type objcScalar struct {
+	// gpb is the type name used by GPB<gpb>Array and GPBString<gpb>Dictionary.
+	gpb    string
+	ctype  string
+	reader string
+}
+
+var objcScalars = map[gdescriptor.FieldDescriptorProto_Type]objcScalar{
+	gdescriptor.FieldDescriptorProto_TYPE_DOUBLE:   {"Double", "double", "[%s doubleValue]"},
+	gdescriptor.FieldDescriptorProto_TYPE_FLOAT:    {"Float", "float", "[%s floatValue]"},
+	gdescriptor.FieldDescriptorProto_TYPE_INT64:    {"Int64", "int64_t", "[%s longLongValue]"},
+	gdescriptor.FieldDescriptorProto_TYPE_SINT64:   {"Int64", "int64_t", "[%s longLongValue]"},
+	gdescriptor.FieldDescriptorProto_TYPE_SFIXED64: {"Int64", "int64_t", "[%s longLongValue]"},
+	gdescriptor.FieldDescriptorProto_TYPE_UINT64:   {"UInt64", "uint64_t", "RNGrpcUInt64(%s)"},
+	gdescriptor.FieldDescriptorProto_TYPE_FIXED64:  {"UInt64", "uint64_t", "RNGrpcUInt64(%s)"},
+	gdescriptor.FieldDescriptorProto_TYPE_INT32:    {"Int32", "int32_t", "[%s intValue]"},
+	gdescriptor.FieldDescriptorProto_TYPE_SINT32:   {"Int32", "int32_t", "[%s intValue]"},
+	gdescriptor.FieldDescriptorProto_TYPE_SFIXED32: {"Int32", "int32_t", "[%s intValue]"},
+	gdescriptor.FieldDescriptorProto_TYPE_UINT32:   {"UInt32", "uint32_t", "[%s unsignedIntValue]"},
+	gdescriptor.FieldDescriptorProto_TYPE_FIXED32:  {"UInt32", "uint32_t", "[%s unsignedIntValue]"},
+	gdescriptor.FieldDescriptorProto_TYPE_BOOL:     {"Bool", "BOOL", "[%s boolValue]"},
+	gdescriptor.FieldDescriptorProto_TYPE_ENUM:     {"RawValue", "int32_t", "[%s intValue]"},
+}
+
+// objcReservedNames are field names protoc-gen-objc suffixes with "_p" so
+// they do not clash with NSObject or the language.
+var objcReservedNames = map[string]bool{
+	"id": true, "description": true, "hash": true, "class": true, "self": true,
+	"super": true, "copy": true, "zone": true, "retain": true, "release": true,
+	"autorelease": true, "retainCount": true, "delete": true, "debugDescription": true,
+	"init": true, "new": true, "alloc": true, "bool": true, "int": true, "long": true,
+	"float": true, "double": true, "char": true, "void": true, "unsigned": true,
+	"signed": true, "struct": true, "union": true, "const": true, "static": true,
+	"extern": true, "register": true, "volatile": true, "default": true, "case": true,
+	"switch": true, "if": true, "else": true, "for": true, "while": true, "do": true,
+	"break": true, "continue": true, "return": true, "goto": true, "sizeof": true,
+	"typedef": true, "enum": true, "auto": true, "inline": true, "restrict": true,
+	"nil": true, "Nil": true, "YES": true, "NO": true, "BOOL": true, "SEL": true,
+	"IMP": true, "Class": true, "Protocol": true, "bycopy": true, "byref": true,
+	"oneway": true, "in": true, "out": true, "inout": true,
+}
+
+var objcUpperSegments = map[string]bool{"url": true, "http": true, "https": true}
+
+// objcCamelCase mirrors protoc-gen-objc's UnderscoresToCamelCase.
+func objcCamelCase(name string, upperFirst bool) string {
+	var words []string
+	current := ""
+	var lastNumber, lastLower bool
+	for _, c := range name {
+		switch {
+		case unicode.IsDigit(c):
+			if !lastNumber {
+				words = append(words, current)
+				current = ""
+			}
+			current += string(c)
+			lastNumber, lastLower = true, false
+		case unicode.IsLower(c):
+			if lastNumber {
+				words = append(words, current)
+				current = ""
+			}
+			current += string(c)
+			lastNumber, lastLower = false, true
+		case unicode.IsUpper(c):
+			if lastLower {
+				words = append(words, current)
+				current = ""
+			}
+			current += string(c)
+			lastNumber, lastLower = false, false
+		default:
+			words = append(words, current)
+			current = ""
+			lastNumber, lastLower = false, false
+		}
+	}
+	words = append(words, current)
+
+	result := ""
+	for _, w := range words {
+		if w == "" {
+			continue
+		}
+		if objcUpperSegments[strings.ToLower(w)] && (result != "" || upperFirst) {
+			result += strings.ToUpper(w)
+		} else {
+			result += strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	if result != "" && !upperFirst {
+		result = strings.ToLower(result[:1]) + result[1:]
+	}
+	return result
+}
+
+// objcFieldName returns the property name protoc-gen-objc gives to f.
+func (g *generator) objcFieldName(f *gdescriptor.FieldDescriptorProto) string {
+	name := objcCamelCase(f.GetName(), false)
+	if f.GetLabel() == gdescriptor.FieldDescriptorProto_LABEL_REPEATED && !g.isMapField(f) {
+		name += "Array"
+	}
+	if objcReservedNames[name] {
+		return name + "_p"
+	}
+	for _, prefix := range []string{"alloc", "new", "copy", "mutableCopy", "init"} {
+		if strings.HasPrefix(name, prefix) && (len(name) == len(prefix) || unicode.IsUpper(rune(name[len(prefix)]))) {
+			return name + "_p"
+		}
+	}
+	return name
+}
+
+func objcPrefix(file *descriptor.File) string {
+	return file.GetOptions().GetObjcClassPrefix()
+}
+
+func (g *generator) objcMessageName(m *descriptor.Message) string {
+	return objcPrefix(m.File) + strings.Join(append(append([]string{}, m.Outers...), m.GetName()), "_")
+}
+
+func (g *generator) objcTypeName(f *gdescriptor.FieldDescriptorProto) (string, error) {
+	switch f.GetType() {
+	case gdescriptor.FieldDescriptorProto_TYPE_STRING:
+		return "NSString", nil
+	case gdescriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "NSData", nil
+	case gdescriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		m, err := g.reg.LookupMsg("", f.GetTypeName())
+		if err != nil {
+			return "", err
+		}
+		return g.objcMessageName(m), nil
+	}
+	return "", fmt.Errorf("%s is not an object type", f.GetName())
+}
+
+// objcFileBase returns the path protoc-gen-objc uses for the files generated from file,
+// without an extension.
+func objcFileBase(file *descriptor.File) string {
+	name := strings.TrimSuffix(file.GetName(), filepath.Ext(file.GetName()))
+	dir, base := path.Split(name)
+	return dir + objcCamelCase(base, true)
+}
+
+func objcImport(file *descriptor.File, ext string) string {
+	if strings.HasPrefix(file.GetName(), "google/protobuf/") {
+		return fmt.Sprintf("#import <Protobuf/GPB%s%s>\n", path.Base(objcFileBase(file)), ext)
+	}
+	return fmt.Sprintf("#import \"%s%s\"\n", objcFileBase(file), ext)
+}
+
+// objcFromJS returns an expression converting the JS value expr to the
+// Objective-C representation of a single f value.
+func (g *generator) objcFromJS(f *gdescriptor.FieldDescriptorProto, expr string) (string, error) {
+	switch f.GetType() {
+	case gdescriptor.FieldDescriptorProto_TYPE_STRING:
+		return expr, nil
+	case gdescriptor.FieldDescriptorProto_TYPE_BYTES:
+		if g.Bytes == "utf8" {
+			return fmt.Sprintf("[%s dataUsingEncoding:NSUTF8StringEncoding]", expr), nil
+		}
+		return fmt.Sprintf("[[NSData alloc] initWithBase64EncodedString:%s options:0]", expr), nil
+	case gdescriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if isWellKnownJSON(f.GetTypeName()) {
+			return g.objcWellKnownFromJS(f.GetTypeName(), expr)
+		}
+		name, err := g.objcTypeName(f)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%sFromDictionary(%s, error)", name, expr), nil
+	}
+	if s, ok := objcScalars[f.GetType()]; ok {
+		return fmt.Sprintf(s.reader, expr), nil
+	}
+	return "", fmt.Errorf("unsupported field type %s", f.GetType())
+}
+
+// objcToJS returns an expression converting the Objective-C value expr of f
+// to a JS value.
+func (g *generator) objcToJS(f *gdescriptor.FieldDescriptorProto, expr string) (string, error) {
+	switch f.GetType() {
+	case gdescriptor.FieldDescriptorProto_TYPE_STRING:
+		return expr, nil
+	case gdescriptor.FieldDescriptorProto_TYPE_BYTES:
+		if g.Bytes == "utf8" {
+			return fmt.Sprintf("[[NSString alloc] initWithData:%s encoding:NSUTF8StringEncoding]", expr), nil
+		}
+		return fmt.Sprintf("[%s base64EncodedStringWithOptions:0]", expr), nil
+	case gdescriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if isWellKnownJSON(f.GetTypeName()) {
+			return g.objcWellKnownToJS(f.GetTypeName(), expr)
+		}
+		name, err := g.objcTypeName(f)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%sToDictionary(%s)", name, expr), nil
+	case gdescriptor.FieldDescriptorProto_TYPE_INT64,
+		gdescriptor.FieldDescriptorProto_TYPE_SINT64,
+		gdescriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		if g.Int64 == "number" {
+			break
+		}
+		return fmt.Sprintf("[NSString stringWithFormat:@\"%%lld\", %s]", expr), nil
+	case gdescriptor.FieldDescriptorProto_TYPE_UINT64,
+		gdescriptor.FieldDescriptorProto_TYPE_FIXED64:
+		if g.Int64 == "number" {
+			break
+		}
+		return fmt.Sprintf("[NSString stringWithFormat:@\"%%llu\", %s]", expr), nil
+	}
+	return fmt.Sprintf("@(%s)", expr), nil
+}
+
+// objcWellKnownFromJS returns an expression converting the JS value expr to
+// the well-known type typeName.
+func (g *generator) objcWellKnownFromJS(typeName, expr string) (string, error) {
+	switch {
+	case typeName == wktTimestamp && g.Timestamp == "ms":
+		g.useHelper("timestampMillis", objcTimestampMillisHelpers)
+		return fmt.Sprintf("RNGrpcTimestampFromMillis(%s)", expr), nil
+	case typeName == wktTimestamp:
+		g.useHelper("nanos", objcNanosHelpers)
+		g.useHelper("timestamp", objcTimestampHelpers)
+		return fmt.Sprintf("RNGrpcTimestampFromString(%s)", expr), nil
+	case typeName == wktDuration:
+		g.useHelper("nanos", objcNanosHelpers)
+		g.useHelper("duration", objcDurationHelpers)
+		return fmt.Sprintf("RNGrpcDurationFromString(%s)", expr), nil
+	}
+	if typeName == wktAny {
+		return fmt.Sprintf("RNGrpcAnyFromJS(%s, error)", expr), nil
+	}
+	if isStructType(typeName) {
+		g.useHelper("struct", objcStructHelpers)
+	} else if err := g.useObjCWrapperHelpers(typeName); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("RNGrpc%sFromJS(%s)", wellKnownName(typeName), expr), nil
+}
+
+// objcWellKnownToJS returns an expression converting the well-known type
+// typeName expr to a JS value.
+func (g *generator) objcWellKnownToJS(typeName, expr string) (string, error) {
+	switch {
+	case typeName == wktTimestamp && g.Timestamp == "ms":
+		g.useHelper("timestampMillis", objcTimestampMillisHelpers)
+		return fmt.Sprintf("RNGrpcTimestampToMillis(%s)", expr), nil
+	case typeName == wktTimestamp:
+		g.useHelper("nanos", objcNanosHelpers)
+		g.useHelper("timestamp", objcTimestampHelpers)
+		return fmt.Sprintf("RNGrpcTimestampToString(%s)", expr), nil
+	case typeName == wktDuration:
+		g.useHelper("nanos", objcNanosHelpers)
+		g.useHelper("duration", objcDurationHelpers)
+		return fmt.Sprintf("RNGrpcDurationToString(%s)", expr), nil
+	}
+	if isStructType(typeName) {
+		g.useHelper("struct", objcStructHelpers)
+	} else if typeName != wktAny {
+		if err := g.useObjCWrapperHelpers(typeName); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("RNGrpc%sToJS(%s)", wellKnownName(typeName), expr), nil
+}
+
+// useObjCWrapperHelpers records the functions converting the wrapper message
+// typeName from and to its nullable value.
+func (g *generator) useObjCWrapperHelpers(typeName string) error {
+	valueField, err := g.wrapperValueField(typeName)
+	if err != nil {
+		return err
+	}
+	fromJS, err := g.objcFromJS(valueField, "value")
+	if err != nil {
+		return err
+	}
+	toJS, err := g.objcToJS(valueField, "msg.value")
+	if err != nil {
+		return err
+	}
+	name := wellKnownName(typeName)
+	g.useHelper("wrapper"+name, fasttemplate.ExecuteString(objcWrapperHelpers, "{{", "}}", map[string]interface{}{
+		"name":   name,
+		"fromJS": fromJS,
+		"toJS":   toJS,
+	}))
+	return nil
+}
+
+// writeObjCAnyRegistry writes the functions packing and unpacking messages,
+// and the well-known types, to and from the JSON form of google.protobuf.Any.
+// Unknown types fail the conversion with INVALID_ARGUMENT.
+func (g *generator) writeObjCAnyRegistry(messages []*descriptor.Message, buf io.Writer) error {
+	wkts := anyWellKnownTypes(messages)
+	fmt.Fprint(buf, "\nstatic GPBAny *RNGrpcAnyFromJS(NSDictionary *value, NSError **error) {\n    NSString *typeURL = value[@\"@type\"];\n    GPBMessage *msg = nil;\n    ")
+	for _, m := range messages {
+		fmt.Fprintf(buf, "if ([typeURL isEqualToString:@%q]) {\n        msg = %sFromDictionary(value, error);\n    } else ", anyTypeURL(m), g.objcMessageName(m))
+	}
+	for _, t := range wkts {
+		from, err := g.objcWellKnownFromAny(t)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "if ([typeURL isEqualToString:@%q]) {\n        msg = %s;\n    } else ", wellKnownTypeURL(t), from)
+	}
+	fmt.Fprint(buf, `{
+        NSString *message = [NSString stringWithFormat:@"unknown type %@", typeURL];
+        *error = [NSError errorWithDomain:kGRPCErrorDomain code:GRPCErrorCodeInvalidArgument userInfo:@{NSLocalizedDescriptionKey: message}];
+    }
+    return msg != nil ? [GPBAny anyWithMessage:msg error:NULL] : nil;
+}
+`)
+
+	fmt.Fprint(buf, "\nstatic NSDictionary *RNGrpcAnyToJS(GPBAny *any) {\n    NSMutableDictionary *out = [NSMutableDictionary dictionary];\n")
+	for i, m := range messages {
+		name := g.objcMessageName(m)
+		if i > 0 {
+			fmt.Fprint(buf, " else ")
+		} else {
+			fmt.Fprint(buf, "    ")
+		}
+		fmt.Fprintf(buf, "if ([any.typeURL isEqualToString:@%q]) {\n        %s *msg = (%s *)[any unpackMessageClass:[%s class] error:NULL];\n        if (msg != nil) {\n            [out addEntriesFromDictionary:%sToDictionary(msg)];\n        }\n    }", anyTypeURL(m), name, name, name, name)
+	}
+	for i, t := range wkts {
+		name := "GPB" + wellKnownName(t)
+		to, err := g.objcWellKnownToAny(t, "msg")
+		if err != nil {
+			return err
+		}
+		if i > 0 || len(messages) > 0 {
+			fmt.Fprint(buf, " else ")
+		} else {
+			fmt.Fprint(buf, "    ")
+		}
+		fmt.Fprintf(buf, "if ([any.typeURL isEqualToString:@%q]) {\n        %s *msg = (%s *)[any unpackMessageClass:[%s class] error:NULL];\n        if (msg != nil) {\n            out[@\"value\"] = %s;\n        }\n    }", wellKnownTypeURL(t), name, name, name, to)
+	}
+	fmt.Fprint(buf, "\n    out[@\"@type\"] = any.typeURL;\n    return out;\n}\n")
+	return nil
+}
+
+// objcAnyHeaders are the headers of the well-known types the Any registry
+// packs.
+var objcAnyHeaders = []string{"Any", "Duration", "Empty", "FieldMask", "Struct", "Timestamp", "Wrappers"}
+
+// objcWellKnownFromAny returns the expression converting the value of the
+// JSON form of an Any holding the well-known type typeName, in value.
+func (g *generator) objcWellKnownFromAny(typeName string) (string, error) {
+	switch typeName {
+	case wktEmpty:
+		return "[GPBEmpty message]", nil
+	case wktFieldMask:
+		g.useHelper("fieldMask", objcFieldMaskHelpers)
+		return `RNGrpcFieldMaskFromJS(RNGrpcValue(value, @"value"))`, nil
+	}
+	return g.objcWellKnownFromJS(typeName, `RNGrpcValue(value, @"value")`)
+}
+
+// objcWellKnownToAny returns the expression converting the well-known type
+// typeName msg to the value of the JSON form of the Any holding it.
+func (g *generator) objcWellKnownToAny(typeName, msg string) (string, error) {
+	switch typeName {
+	case wktEmpty:
+		return "@{}", nil
+	case wktFieldMask:
+		g.useHelper("fieldMask", objcFieldMaskHelpers)
+		return "RNGrpcFieldMaskToJS(" + msg + ")", nil
+	}
+	return g.objcWellKnownToJS(typeName, msg)
+}
+
+// objcResult returns the value a call resolves with for response.
+func (g *generator) objcResult(response *descriptor.Message) string {
+	if isEmptyMessage(response) {
+		return "[NSNull null]"
+	}
+	return g.objcMessageName(response) + "ToDictionary(response)"
+}
+
+// objcFallible reports whether the conversion of a JS value of f returns nil
+// for a value it cannot convert, like a malformed timestamp or base64 string.
+func objcFallible(f *gdescriptor.FieldDescriptorProto) bool {
+	return f.GetType() == gdescriptor.FieldDescriptorProto_TYPE_MESSAGE || f.GetType() == gdescriptor.FieldDescriptorProto_TYPE_BYTES
+}
+
+func (g *generator) objcFieldFromDictionary(m *descriptor.Message, f *descriptor.Field, buf io.Writer) error {
+	className := g.objcMessageName(m)
+	name := g.objcFieldName(f.FieldDescriptorProto)
+	fmt.Fprintf(buf, "    if ((value = RNGrpcValue(in, @\"%s\"))) {\n", f.GetJsonName())
+	if g.isMapField(f.FieldDescriptorProto) {
+		valueField, err := g.mapValueField(f.FieldDescriptorProto)
+		if err != nil {
+			return err
+		}
+		item, err := g.objcFromJS(valueField, "value[key]")
+		if err != nil {
+			return err
+		}
+		if s, ok := objcScalars[valueField.GetType()]; ok {
+			fmt.Fprintf(buf, "        for (NSString *key in value) {\n            [msg.%s set%s:%s forKey:key];\n        }\n", name, s.gpb, item)
+		} else if objcFallible(valueField) {
+			fmt.Fprintf(buf, "        for (NSString *key in value) {\n            id item = %s;\n            if (item == nil) {\n                return RNGrpcInvalidField(error, @\"%s\");\n            }\n            msg.%s[key] = item;\n        }\n", item, f.GetJsonName(), name)
+		} else {
+			fmt.Fprintf(buf, "        for (NSString *key in value) {\n            msg.%s[key] = %s;\n        }\n", name, item)
+		}
+	} else if f.GetLabel() == gdescriptor.FieldDescriptorProto_LABEL_REPEATED {
+		item, err := g.objcFromJS(f.FieldDescriptorProto, "item")
+		if err != nil {
+			return err
+		}
+		add := "addObject"
+		if f.GetType() == gdescriptor.FieldDescriptorProto_TYPE_ENUM {
+			add = "addRawValue"
+		} else if _, ok := objcScalars[f.GetType()]; ok {
+			add = "addValue"
+		}
+		if objcFallible(f.FieldDescriptorProto) {
+			fmt.Fprintf(buf, "        for (id item in value) {\n            id converted = %s;\n            if (converted == nil) {\n                return RNGrpcInvalidField(error, @\"%s\");\n            }\n            [msg.%s %s:converted];\n        }\n", item, f.GetJsonName(), name, add)
+		} else {
+			fmt.Fprintf(buf, "        for (id item in value) {\n            [msg.%s %s:%s];\n        }\n", name, add, item)
+		}
+	} else if f.GetType() == gdescriptor.FieldDescriptorProto_TYPE_ENUM {
+		fmt.Fprintf(buf, "        Set%s_%s_RawValue(msg, [value intValue]);\n", className, objcCamelCase(f.GetName(), true))
+	} else {
+		item, err := g.objcFromJS(f.FieldDescriptorProto, "value")
+		if err != nil {
+			return err
+		}
+		if objcFallible(f.FieldDescriptorProto) {
+			fmt.Fprintf(buf, "        if (!(msg.%s = %s)) {\n            return RNGrpcInvalidField(error, @\"%s\");\n        }\n", name, item, f.GetJsonName())
+		} else {
+			fmt.Fprintf(buf, "        msg.%s = %s;\n", name, item)
+		}
+	}
+	fmt.Fprint(buf, "    }\n")
+	return nil
+}
+
+func (g *generator) objcFieldToDictionary(m *descriptor.Message, f *descriptor.Field, buf io.Writer) error {
+	className := g.objcMessageName(m)
+	name := g.objcFieldName(f.FieldDescriptorProto)
+	key := f.GetJsonName()
+	if g.isMapField(f.FieldDescriptorProto) {
+		valueField, err := g.mapValueField(f.FieldDescriptorProto)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(buf, "    {\n        NSMutableDictionary *map = [NSMutableDictionary dictionary];\n")
+		if s, ok := objcScalars[valueField.GetType()]; ok {
+			item, err := g.objcToJS(valueField, "v")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(buf, "        [msg.%s enumerateKeysAnd%ssUsingBlock:^(NSString *key, %s v, BOOL *stop) {\n            map[key] = %s;\n        }];\n", name, s.gpb, s.ctype, item)
+		} else {
+			item, err := g.objcToJS(valueField, fmt.Sprintf("msg.%s[key]", name))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(buf, "        for (NSString *key in msg.%s) {\n            map[key] = %s;\n        }\n", name, item)
+		}
+		fmt.Fprintf(buf, "        out[@\"%s\"] = map;\n    }\n", key)
+	} else if f.GetLabel() == gdescriptor.FieldDescriptorProto_LABEL_REPEATED {
+		fmt.Fprint(buf, "    {\n        NSMutableArray *list = [NSMutableArray array];\n")
+		if s, ok := objcScalars[f.GetType()]; ok {
+			item, err := g.objcToJS(f.FieldDescriptorProto, "v")
+			if err != nil {
+				return err
+			}
+			enumerate := "enumerateValuesWithBlock"
+			if f.GetType() == gdescriptor.FieldDescriptorProto_TYPE_ENUM {
+				enumerate = "enumerateRawValuesWithBlock"
+			}
+			fmt.Fprintf(buf, "        [msg.%s %s:^(%s v, NSUInteger idx, BOOL *stop) {\n            [list addObject:%s];\n        }];\n", name, enumerate, s.ctype, item)
+		} else {
+			typeName, err := g.objcTypeName(f.FieldDescriptorProto)
+			if err != nil {
+				return err
+			}
+			item, err := g.objcToJS(f.FieldDescriptorProto, "item")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(buf, "        for (%s *item in msg.%s) {\n            [list addObject:%s];\n        }\n", typeName, name, item)
+		}
+		fmt.Fprintf(buf, "        out[@\"%s\"] = list;\n    }\n", key)
+	} else if f.GetType() == gdescriptor.FieldDescriptorProto_TYPE_ENUM {
+		fmt.Fprintf(buf, "    out[@\"%s\"] = @(%s_%s_RawValue(msg));\n", key, className, objcCamelCase(f.GetName(), true))
+	} else {
+		item, err := g.objcToJS(f.FieldDescriptorProto, "msg."+name)
+		if err != nil {
+			return err
+		}
+		if f.GetType() == gdescriptor.FieldDescriptorProto_TYPE_MESSAGE && f.OneofIndex == nil && isWellKnownJSON(f.GetTypeName()) {
+			fmt.Fprintf(buf, "    out[@\"%s\"] = msg.has%s ? %s : (id)[NSNull null];\n", key, objcCamelCase(f.GetName(), true), item)
+		} else if f.GetType() == gdescriptor.FieldDescriptorProto_TYPE_MESSAGE && f.OneofIndex == nil {
+			fmt.Fprintf(buf, "    if (msg.has%s) {\n        out[@\"%s\"] = %s;\n    }\n", objcCamelCase(f.GetName(), true), key, item)
+		} else {
+			fmt.Fprintf(buf, "    out[@\"%s\"] = %s;\n", key, item)
+		}
+	}
+	return nil
+}
+
+// generateObjCConverters writes a pair of static functions converting
+// between m and NSDictionary.
+func (g *generator) generateObjCConverters(m *descriptor.Message, buf io.Writer) error {
+	className := g.objcMessageName(m)
+	fmt.Fprintf(buf, "\nstatic %s *%sFromDictionary(NSDictionary *in, NSError **error) {\n    %s *msg = [%s message];\n    id value;\n", className, className, className, className)
+	for _, f := range m.Fields {
+		if err := g.objcFieldFromDictionary(m, f, buf); err != nil {
+			return err
+		}
+	}
+	fmt.Fprint(buf, "    return msg;\n}\n")
+
+	fmt.Fprintf(buf, "\nstatic NSDictionary *%sToDictionary(%s *msg) {\n    NSMutableDictionary *out = [NSMutableDictionary dictionary];\n", className, className)
+	for _, f := range m.Fields {
+		if f.OneofIndex == nil {
+			if err := g.objcFieldToDictionary(m, f, buf); err != nil {
+				return err
+			}
+		}
+	}
+	for oi, o := range m.GetOneofDecl() {
+		for _, f := range m.Fields {
+			if f.OneofIndex == nil || f.GetOneofIndex() != int32(oi) {
+				continue
+			}
+			fmt.Fprintf(buf, "    if (msg.%sOneOfCase == %s_%s_OneOfCase_%s) {\n", objcCamelCase(o.GetName(), false), className, objcCamelCase(o.GetName(), true), objcCamelCase(f.GetName(), true))
+			var inner bytes.Buffer
+			if err := g.objcFieldToDictionary(m, f, &inner); err != nil {
+				return err
+			}
+			fmt.Fprint(buf, "    "+strings.Replace(strings.TrimSuffix(inner.String(), "\n"), "\n", "\n    ", -1)+"\n    }\n")
+		}
+	}
+	fmt.Fprint(buf, "    return out;\n}\n")
+	return nil
+}
+
+func (g *generator) generateObjCMethod(m *descriptor.Method, buf io.Writer) error {
+	params := map[string]interface{}{
+		"methodName":   ToJsonName(m.GetName()),
+		"bigName":      strings.Title(ToJsonName(m.GetName())),
+		"rpcName":      "RPCTo" + strings.Title(m.GetName()),
+		"serviceName":  m.Service.GetName(),
+		"requestType":  g.objcMessageName(m.RequestType),
+		"responseType": g.objcMessageName(m.ResponseType),
+		"result":       g.objcResult(m.ResponseType),
+	}
+	var temp string
+	switch {
+	case !m.GetClientStreaming() && !m.GetServerStreaming():
+		temp = `
+RCT_EXPORT_METHOD({{methodName}}:(NSDictionary *)in
+                  options:(NSDictionary *)options
+                  resolver:(RCTPromiseResolveBlock)resolve
+                  rejecter:(RCTPromiseRejectBlock)reject)
+{
+    NSError *invalid = nil;
+    {{requestType}} *request = {{requestType}}FromDictionary(in, &invalid);
+    if (request == nil) {
+        RNGrpcReject(reject, invalid);
+        return;
+    }
+    BOOL withMetadata = [RNGrpcValue(options, @"withMetadata") boolValue];
+    NSString *requestID = RNGrpcValue(options, @"requestId");
+    __block __weak GRPCProtoCall *weakCall = nil;
+    GRPCProtoCall *call = [[self service] {{rpcName}}WithRequest:request
+                                                         handler:^({{responseType}} *response, NSError *error) {
+        if (requestID != nil) {
+            [self removeCall:requestID unary:YES];
+        }
+        if (error != nil) {
+            RNGrpcReject(reject, error);
+            return;
+        }
+        if (response == nil) {
+            reject(@"null", @"response is null", nil);
+            return;
+        }
+        resolve(withMetadata ? RNGrpcWithMetadata(weakCall, {{result}}) : {{result}});
+    }];
+    weakCall = call;
+    [[GrpcEngine sharedEngine] attachHeaders:call];
+    NSError *unsupported = RNGrpcApplyCallOptions(call, options);
+    if (unsupported != nil) {
+        RNGrpcReject(reject, unsupported);
+        return;
+    }
+    if (requestID != nil && ![self addCall:call forID:requestID unary:YES]) {
+        RNGrpcRejectInFlight(reject, requestID);
+        return;
+    }
+    [call start];
+}
+`
+	case !m.GetClientStreaming() && m.GetServerStreaming():
+		temp = `
+RCT_EXPORT_METHOD({{methodName}}:(NSDictionary *)in
+                  options:(NSDictionary *)options
+                  resolver:(RCTPromiseResolveBlock)resolve
+                  rejecter:(RCTPromiseRejectBlock)reject)
+{
+    NSError *invalid = nil;
+    {{requestType}} *request = {{requestType}}FromDictionary(in, &invalid);
+    if (request == nil) {
+        RNGrpcReject(reject, invalid);
+        return;
+    }
+    NSString *eventID = [[NSUUID UUID] UUIDString];
+    __block __weak GRPCProtoCall *weakCall = nil;
+    GRPCProtoCall *call = [[self service] {{rpcName}}WithRequest:request
+                                                    eventHandler:^(BOOL done, {{responseType}} *response, NSError *error) {
+        // Calls cancelled from javascript already sent their last event.
+        if (done && [self removeCall:eventID unary:NO] == nil) {
+            return;
+        }
+        [self sendEvent:eventID done:done data:(response != nil ? {{responseType}}ToDictionary(response) : nil) error:error trailers:weakCall.responseTrailers];
+    }];
+    weakCall = call;
+    [[GrpcEngine sharedEngine] attachHeaders:call];
+    NSError *unsupported = RNGrpcApplyCallOptions(call, options);
+    if (unsupported != nil) {
+        RNGrpcReject(reject, unsupported);
+        return;
+    }
+    [self addCall:call forID:eventID unary:NO];
+    [call start];
+    resolve(eventID);
+}
+`
+	case m.GetClientStreaming() && !m.GetServerStreaming():
+		temp = `
+RCT_EXPORT_METHOD(start{{bigName}}:(NSDictionary *)options
+                  resolver:(RCTPromiseResolveBlock)resolve
+                  rejecter:(RCTPromiseRejectBlock)reject)
+{
+    {{serviceName}}ModuleStream *stream = [{{serviceName}}ModuleStream new];
+    NSString *streamID = stream.streamID;
+    stream.withMetadata = [RNGrpcValue(options, @"withMetadata") boolValue];
+    stream.call = [[self service] {{rpcName}}WithRequestsWriter:stream.pipe
+                                                        handler:^({{responseType}} *response, NSError *error) {
+        @synchronized (self) {
+            if (self->_streams[streamID] == stream) {
+                [self->_streams removeObjectForKey:streamID];
+            }
+        }
+        [stream finishWithResponse:(response != nil ? {{result}} : nil) error:error];
+    }];
+    [[GrpcEngine sharedEngine] attachHeaders:stream.call];
+    NSError *unsupported = RNGrpcApplyCallOptions(stream.call, options);
+    if (unsupported != nil) {
+        RNGrpcReject(reject, unsupported);
+        return;
+    }
+    @synchronized (self) {
+        if (_streams == nil) {
+            _streams = [NSMutableDictionary dictionary];
+        }
+        _streams[streamID] = stream;
+    }
+    [stream.call start];
+    resolve(streamID);
+}
+
+// Sends in to the stream, or half-closes it after sending in, if any, with
+// "complete", or cancels it with "error". "complete" resolves the response,
+// the other actions resolve once the message is queued and reject once the
+// stream is closed, or half-closed but for "error".
+RCT_EXPORT_METHOD({{methodName}}:(NSString *)streamID
+                  action:(NSString *)action
+                  event:(NSDictionary *)in
+                  resolver:(RCTPromiseResolveBlock)resolve
+                  rejecter:(RCTPromiseRejectBlock)reject)
+{
+    BOOL cancelling = [action isEqualToString:@"error"];
+    {{serviceName}}ModuleStream *stream;
+    @synchronized (self) {
+        stream = _streams[streamID];
+        if (cancelling) {
+            [_streams removeObjectForKey:streamID];
+        }
+    }
+    if (stream == nil) {
+        reject(@"not_found", [NSString stringWithFormat:@"stream %@ is not open", streamID], nil);
+        return;
+    }
+    if (stream.halfClosed && !cancelling) {
+        reject(@"half_closed", [NSString stringWithFormat:@"stream %@ is half-closed", streamID], nil);
+        return;
+    }
+    NSError *invalid = nil;
+    {{requestType}} *request = in != nil && !cancelling ? {{requestType}}FromDictionary(in, &invalid) : nil;
+    if (invalid != nil) {
+        RNGrpcReject(reject, invalid);
+        return;
+    }
+    if ([action isEqualToString:@"complete"]) {
+        stream.halfClosed = YES;
+        if (request != nil) {
+            [stream.pipe writeValue:request];
+        }
+        [stream.pipe writesFinishedWithError:nil];
+        [stream awaitWithResolver:resolve rejecter:reject];
+    } else if (cancelling) {
+        [stream.call cancel];
+        resolve(nil);
+    } else if (request == nil) {
+        RNGrpcRejectInvalid(reject, [NSString stringWithFormat:@"stream %@ needs a message to send", streamID]);
+    } else {
+        [stream.pipe writeValue:request];
+        resolve(nil);
+    }
+}
+`
+	default:
+		temp = `
+RCT_EXPORT_METHOD(start{{bigName}}:(NSDictionary *)options
+                  resolver:(RCTPromiseResolveBlock)resolve
+                  rejecter:(RCTPromiseRejectBlock)reject)
+{
+    {{serviceName}}ModuleStream *stream = [{{serviceName}}ModuleStream new];
+    NSString *streamID = stream.streamID;
+    stream.call = [[self service] {{rpcName}}WithRequestsWriter:stream.pipe
+                                                   eventHandler:^(BOOL done, {{responseType}} *response, NSError *error) {
+        if (done) {
+            @synchronized (self) {
+                [self->_biStreams removeObjectForKey:streamID];
+            }
+        }
+        // Streams ended from javascript fail with the status it gave.
+        if (done && stream.cancelError != nil) {
+            error = stream.cancelError;
+        }
+        [self sendEvent:streamID done:done data:(response != nil ? {{responseType}}ToDictionary(response) : nil) error:error trailers:stream.call.responseTrailers];
+    }];
+    [[GrpcEngine sharedEngine] attachHeaders:stream.call];
+    NSError *unsupported = RNGrpcApplyCallOptions(stream.call, options);
+    if (unsupported != nil) {
+        RNGrpcReject(reject, unsupported);
+        return;
+    }
+    @synchronized (self) {
+        if (_biStreams == nil) {
+            _biStreams = [NSMutableDictionary dictionary];
+        }
+        _biStreams[streamID] = stream;
+    }
+    [stream.call start];
+    resolve(streamID);
+}
+
+// Sends in to the stream, or half-closes it after sending in, if any, with
+// "complete", or cancels it with the code and message of in with "error". The
+// promise resolves once the message is queued and rejects once the stream is
+// closed, or half-closed but for "error".
+RCT_EXPORT_METHOD({{methodName}}:(NSString *)streamID
+                  action:(NSString *)action
+                  event:(NSDictionary *)in
+                  resolver:(RCTPromiseResolveBlock)resolve
+                  rejecter:(RCTPromiseRejectBlock)reject)
+{
+    BOOL cancelling = [action isEqualToString:@"error"];
+    {{serviceName}}ModuleStream *stream;
+    @synchronized (self) {
+        stream = _biStreams[streamID];
+        if (cancelling) {
+            [_biStreams removeObjectForKey:streamID];
+        }
+    }
+    if (stream == nil) {
+        reject(@"not_found", [NSString stringWithFormat:@"stream %@ is not open", streamID], nil);
+        return;
+    }
+    if (stream.halfClosed && !cancelling) {
+        reject(@"half_closed", [NSString stringWithFormat:@"stream %@ is half-closed", streamID], nil);
+        return;
+    }
+    NSError *invalid = nil;
+    {{requestType}} *request = !cancelling ? {{requestType}}FromDictionary(in, &invalid) : nil;
+    if (invalid != nil) {
+        RNGrpcReject(reject, invalid);
+        return;
+    }
+    if ([action isEqualToString:@"complete"]) {
+        stream.halfClosed = YES;
+        if (in != nil) {
+            [stream.pipe writeValue:request];
+        }
+        [stream.pipe writesFinishedWithError:nil];
+    } else if (cancelling) {
+        stream.cancelError = RNGrpcErrorFromJS(in);
+        [stream.call cancel];
+    } else {
+        [stream.pipe writeValue:request];
+    }
+    resolve(nil);
+}
+`
+	}
+	_, err := fasttemplate.Execute(temp, "{{", "}}", buf, params)
+	return err
+}
+
+func (g *generator) generateObjCFile(file *descriptor.File, header string) (string, string, error) {
+	var h, m bytes.Buffer
+	h.WriteString(objcHeader)
+	fasttemplate.Execute(objcImplHeader, "{{", "}}", &m, map[string]interface{}{
+		"header": header,
+	})
+
+	messages := g.reachableMessages(file)
+	imported := map[string]bool{}
+	files := append(messageFiles(messages), g.wellKnownFiles(messages)...)
+	var imports []string
+	for _, f := range append([]*descriptor.File{file}, files...) {
+		imports = append(imports, objcImport(f, ".pbobjc.h"))
+	}
+	if g.usesAny(file) {
+		for _, name := range objcAnyHeaders {
+			imports = append(imports, "#import <Protobuf/GPB"+name+".pbobjc.h>\n")
+		}
+	}
+	for _, line := range imports {
+		if !imported[line] {
+			imported[line] = true
+			m.WriteString(line)
+		}
+	}
+	m.WriteString(objcImport(file, ".pbrpc.h"))
+	m.WriteString(objcHelpers)
+	m.WriteString(errorHelpers(objcErrorHelpers, `@"`))
+
+	var converters bytes.Buffer
+	converters.WriteString("\n")
+	for _, msg := range messages {
+		name := g.objcMessageName(msg)
+		fmt.Fprintf(&converters, "static %s *%sFromDictionary(NSDictionary *in, NSError **error);\n", name, name)
+		fmt.Fprintf(&converters, "static NSDictionary *%sToDictionary(%s *msg);\n", name, name)
+	}
+	if g.usesAny(file) {
+		if err := g.writeObjCAnyRegistry(messages, &converters); err != nil {
+			return "", "", err
+		}
+	}
+	for _, msg := range messages {
+		if err := g.generateObjCConverters(msg, &converters); err != nil {
+			return "", "", err
+		}
+	}
+	g.writeHelpers(&m)
+	m.Write(converters.Bytes())
+
+	for _, svc := range file.Services {
+		glog.V(1).Infof("Service Name %s", svc.GetName())
+		params := map[string]interface{}{
+			"serviceName":  svc.GetName(),
+			"serviceClass": objcPrefix(file) + svc.GetName(),
+			"fullName":     strings.TrimPrefix(file.GetPackage()+"."+svc.GetName(), "."),
+			"protocols":    "RCTBridgeModule",
+		}
+		if hasCancellable(svc) || hasClientStreaming(svc) || hasBiStreaming(svc) {
+			params["protocols"] = "RCTBridgeModule, RCTInvalidating"
+		}
+		fasttemplate.Execute(objcServiceHeader, "{{", "}}", &h, params)
+		fasttemplate.Execute(objcStreamClass, "{{", "}}", &m, params)
+		fasttemplate.Execute(objcServiceTop, "{{", "}}", &m, params)
+		if hasCancellable(svc) {
+			m.WriteString(objcServiceCancel)
+		}
+		writeObjCInvalidate(svc, &m)
+		if hasBiStreaming(svc) {
+			fasttemplate.Execute(objcBiStreamRegistry, "{{", "}}", &m, params)
+		}
+		for _, meth := range svc.Methods {
+			if err := g.generateObjCMethod(meth, &m); err != nil {
+				return "", "", err
+			}
+		}
+		m.WriteString("\n@end\n")
+	}
+	return h.String(), m.String(), nil
+}
+
+// messageFiles returns the files the messages are defined in.
+func messageFiles(messages []*descriptor.Message) []*descriptor.File {
+	var files []*descriptor.File
+	for _, m := range messages {
+		files = append(files, m.File)
+	}
+	return files
+}
+
+// generateObjC emits an RCTBridgeModule for every service, built on the
+// ProtoRPC services of gRPC-ObjC. Like the Java modules, they expect the
+// application to provide a GrpcEngine, here an Objective-C class responding to
+// +sharedEngine, -hostForServiceName:, -attachHeaders: and, for the GrpcConfig
+// module, -configure:options:.
+func (g *generator) generateObjC(targets []*descriptor.File) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	for _, file := range targets {
+		name := file.GetName()
+		base := ToFileName(strings.TrimSuffix(name, filepath.Ext(name))) + "Module"
+		h, m, err := g.generateObjCFile(file, path.Base(base)+".h")
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(base + ".h"),
+			Content: proto.String(h),
+		}, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(base + ".m"),
+			Content: proto.String(m),
+		})
+		glog.V(1).Infof("Will emit %s.h and %s.m", base, base)
+	}
+	return files, nil
+}
@@ -0,0 +1,1026 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	gdescriptor "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/grpc-ecosystem/grpc-gateway/protoc-gen-grpc-gateway/descriptor"
+	"github.com/valyala/fasttemplate"
+)
+
+const (
+	swiftHeader = `// Code generated by protoc-gen-react
+// DO NOT EDIT!
+import Foundation
+import GRPC
+import NIO
+import NIOHPACK
+import React
+import SwiftProtobuf
+
+fileprivate func rnGrpcNumber(_ value: Any?) -> NSNumber? {
+    if let number = value as? NSNumber {
+        return number
+    }
+    if let string = value as? String {
+        return NSDecimalNumber(string: string)
+    }
+    return nil
+}
+
+fileprivate func rnGrpcMetadata(_ headers: HPACKHeaders) -> [String: String] {
+    var out = [String: String]()
+    for (name, value, _) in headers {
+        out[name] = value
+    }
+    return out
+}
+`
+	swiftServiceTop = `
+@objc({{serviceName}}Module)
+class {{serviceName}}Module: RCTEventEmitter {
+{{streams}}
+    override static func requiresMainQueueSetup() -> Bool {
+        return false
+    }
+
+    override func constantsToExport() -> [AnyHashable: Any]! {
+        return ["NAME": "{{fullName}}"]
+    }
+
+    override func supportedEvents() -> [String]! {
+        return []
+    }
+
+    private func client() -> {{clientType}} {
+        let engine = GrpcEngine.shared
+        return {{clientType}}(channel: engine.channel(forServiceName: "{{fullName}}"),
+                              defaultCallOptions: engine.callOptions())
+    }
+
+    // The options javascript passes to a call are applied over those of the
+    // engine. Metadata values, base64 for -bin keys, are sent as they are.
+    private func callOptions(_ options: [String: Any]?) -> CallOptions {
+        var callOptions = GrpcEngine.shared.callOptions()
+        guard let options = options else {
+            return callOptions
+        }
+        if let deadline = rnGrpcNumber(options["deadlineMs"]) {
+            callOptions.timeLimit = .timeout(.milliseconds(deadline.int64Value))
+        }
+        if let metadata = options["metadata"] as? [String: String] {
+            for (key, value) in metadata {
+                callOptions.customMetadata.add(name: key, value: value)
+            }
+        }
+        switch options["compression"] as? String {
+        case "gzip"?:
+            callOptions.messageEncoding = .enabled(.init(forRequests: .gzip, decompressionLimit: .ratio(20)))
+        case "deflate"?:
+            callOptions.messageEncoding = .enabled(.init(forRequests: .deflate, decompressionLimit: .ratio(20)))
+        default:
+            break
+        }
+        return callOptions
+    }
+
+    // Events are emitted through RCTDeviceEventEmitter under the stream id,
+    // exactly like the Android modules do.
+    private func emit(_ eventID: String, done: Bool, data: [String: Any]?, error: GRPCStatus?, trailers: HPACKHeaders? = nil) {
+        var body: [String: Any] = ["done": done]
+        if let data = data {
+            body["data"] = data
+        }
+        if let error = error {
+            body["error"] = error.description
+            body["code"] = rnGrpcCodeName(error)
+            body["userInfo"] = rnGrpcErrorInfo(error, trailers: trailers)
+        }
+        if let trailers = trailers {
+            body["trailers"] = rnGrpcMetadata(trailers)
+        }
+        bridge?.enqueueJSCall("RCTDeviceEventEmitter", method: "emit", args: [eventID, body], completion: nil)
+    }
+
+    private func finish(_ eventID: String, _ result: Result<GRPCStatus, Error>, trailers: HPACKHeaders?) {
+        switch result {
+        case .success(let status) where status.isOk:
+            emit(eventID, done: true, data: nil, error: nil, trailers: trailers)
+        case .success(let status):
+            emit(eventID, done: true, data: nil, error: status, trailers: trailers)
+        case .failure(let error):
+            emit(eventID, done: true, data: nil, error: rnGrpcStatus(error), trailers: trailers)
+        }
+    }
+
+    // Calls made withMetadata resolve {response, headers, trailers}.
+    private func resolveWithMetadata(_ response: Any?, headers: EventLoopFuture<HPACKHeaders>, trailers: EventLoopFuture<HPACKHeaders>, resolve: @escaping RCTPromiseResolveBlock) {
+        headers.and(trailers).whenComplete { result in
+            let (headers, trailers) = (try? result.get()) ?? (HPACKHeaders(), HPACKHeaders())
+            resolve(["response": response ?? NSNull(), "headers": rnGrpcMetadata(headers), "trailers": rnGrpcMetadata(trailers)])
+        }
+    }
+`
+	swiftJSONHelpers = `
+fileprivate func rnGrpcFromJSON<M: SwiftProtobuf.Message>(_ type: M.Type, _ value: Any?) -> M? {
+    guard let string = value as? String else {
+        return nil
+    }
+    return try? M(jsonString: "\"\(string)\"")
+}
+
+fileprivate func rnGrpcToJSON<M: SwiftProtobuf.Message>(_ msg: M) -> Any {
+    guard let json = try? msg.jsonString() else {
+        return NSNull()
+    }
+    return String(json.dropFirst().dropLast())
+}
+`
+	swiftStructHelpers = `
+fileprivate func rnGrpcStructFromJS(_ value: [String: Any]) -> Google_Protobuf_Struct {
+    return Google_Protobuf_Struct(fields: value.mapValues(rnGrpcValueFromJS))
+}
+
+fileprivate func rnGrpcStructToJS(_ msg: Google_Protobuf_Struct) -> [String: Any] {
+    return msg.fields.mapValues(rnGrpcValueToJS)
+}
+
+fileprivate func rnGrpcListValueFromJS(_ value: [Any]) -> Google_Protobuf_ListValue {
+    return Google_Protobuf_ListValue(values: value.map(rnGrpcValueFromJS))
+}
+
+fileprivate func rnGrpcListValueToJS(_ msg: Google_Protobuf_ListValue) -> [Any] {
+    return msg.values.map(rnGrpcValueToJS)
+}
+
+fileprivate func rnGrpcValueFromJS(_ value: Any) -> Google_Protobuf_Value {
+    switch value {
+    case let dict as [String: Any]:
+        return Google_Protobuf_Value(structValue: rnGrpcStructFromJS(dict))
+    case let list as [Any]:
+        return Google_Protobuf_Value(listValue: rnGrpcListValueFromJS(list))
+    case let string as String:
+        return Google_Protobuf_Value(stringValue: string)
+    case let number as NSNumber where CFGetTypeID(number) == CFBooleanGetTypeID():
+        return Google_Protobuf_Value(boolValue: number.boolValue)
+    case let number as NSNumber:
+        return Google_Protobuf_Value(numberValue: number.doubleValue)
+    default:
+        return Google_Protobuf_Value(nilLiteral: ())
+    }
+}
+
+fileprivate func rnGrpcValueToJS(_ msg: Google_Protobuf_Value) -> Any {
+    switch msg.kind {
+    case .boolValue(let value)?:
+        return value
+    case .numberValue(let value)?:
+        return value
+    case .stringValue(let value)?:
+        return value
+    case .structValue(let value)?:
+        return rnGrpcStructToJS(value)
+    case .listValue(let value)?:
+        return rnGrpcListValueToJS(value)
+    case .nullValue?, nil:
+        return NSNull()
+    }
+}
+`
+	swiftBridgeHeader = `// Code generated by protoc-gen-react
+// DO NOT EDIT!
+#import <React/RCTBridgeModule.h>
+#import <React/RCTEventEmitter.h>
+`
+)
+
+// swiftScalars maps scalar proto types to the NSNumber accessor reading the
+// SwiftProtobuf representation of a JS number.
+var swiftScalars = map[gdescriptor.FieldDescriptorProto_Type]string{
+	gdescriptor.FieldDescriptorProto_TYPE_DOUBLE:   "doubleValue",
+	gdescriptor.FieldDescriptorProto_TYPE_FLOAT:    "floatValue",
+	gdescriptor.FieldDescriptorProto_TYPE_INT64:    "int64Value",
+	gdescriptor.FieldDescriptorProto_TYPE_SINT64:   "int64Value",
+	gdescriptor.FieldDescriptorProto_TYPE_SFIXED64: "int64Value",
+	gdescriptor.FieldDescriptorProto_TYPE_UINT64:   "uint64Value",
+	gdescriptor.FieldDescriptorProto_TYPE_FIXED64:  "uint64Value",
+	gdescriptor.FieldDescriptorProto_TYPE_INT32:    "int32Value",
+	gdescriptor.FieldDescriptorProto_TYPE_SINT32:   "int32Value",
+	gdescriptor.FieldDescriptorProto_TYPE_SFIXED32: "int32Value",
+	gdescriptor.FieldDescriptorProto_TYPE_UINT32:   "uint32Value",
+	gdescriptor.FieldDescriptorProto_TYPE_FIXED32:  "uint32Value",
+	gdescriptor.FieldDescriptorProto_TYPE_BOOL:     "boolValue",
+}
+
+var swiftUpperSegments = map[string]bool{"id": true, "url": true, "http": true, "https": true}
+
+// swiftReservedNames are property names SwiftProtobuf suffixes with "_p".
+var swiftReservedNames = map[string]bool{
+	"debugDescription": true, "description": true, "dynamicType": true,
+	"hashValue": true, "isInitialized": true, "unknownFields": true,
+	"init": true, "self": true, "Type": true, "Protocol": true,
+}
+
+// swiftCamelCase mirrors SwiftProtobuf's NamingUtils camel casing.
+func swiftCamelCase(name string, upperFirst bool) string {
+	result := ""
+	for i, w := range strings.Split(name, "_") {
+		if w == "" {
+			continue
+		}
+		switch {
+		case i == 0 && !upperFirst:
+			result += strings.ToLower(w[:1]) + w[1:]
+		case swiftUpperSegments[strings.ToLower(w)]:
+			result += strings.ToUpper(w)
+		default:
+			result += strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	if result != "" && !upperFirst {
+		result = strings.ToLower(result[:1]) + result[1:]
+	}
+	return result
+}
+
+func swiftFieldName(f *gdescriptor.FieldDescriptorProto) string {
+	name := swiftCamelCase(f.GetName(), false)
+	if swiftReservedNames[name] {
+		return name + "_p"
+	}
+	return name
+}
+
+// swiftPrefix returns the prefix SwiftProtobuf derives from the proto package.
+func swiftPrefix(file *descriptor.File) string {
+	if file.GetPackage() == "" {
+		return ""
+	}
+	var parts []string
+	for _, p := range strings.Split(file.GetPackage(), ".") {
+		parts = append(parts, swiftCamelCase(p, true))
+	}
+	return strings.Join(parts, "_") + "_"
+}
+
+func swiftTypeName(file *descriptor.File, outers []string, name string) string {
+	return swiftPrefix(file) + strings.Join(append(append([]string{}, outers...), name), ".")
+}
+
+func (g *generator) swiftMessageName(m *descriptor.Message) string {
+	return swiftTypeName(m.File, m.Outers, m.GetName())
+}
+
+// swiftConverterName returns the name of the file private function converting
+// m from or to a dictionary.
+func (g *generator) swiftConverterName(m *descriptor.Message, direction string) string {
+	return strings.Replace(g.swiftMessageName(m), ".", "_", -1) + direction
+}
+
+// swiftFromJS returns an optional expression converting the JS value expr to
+// the Swift representation of a single f value.
+func (g *generator) swiftFromJS(f *gdescriptor.FieldDescriptorProto, expr string) (string, error) {
+	switch f.GetType() {
+	case gdescriptor.FieldDescriptorProto_TYPE_STRING:
+		return fmt.Sprintf("%s as? String", expr), nil
+	case gdescriptor.FieldDescriptorProto_TYPE_BYTES:
+		if g.Bytes == "utf8" {
+			return fmt.Sprintf("(%s as? String)?.data(using: .utf8)", expr), nil
+		}
+		return fmt.Sprintf("(%s as? String).flatMap({ Data(base64Encoded: $0) })", expr), nil
+	case gdescriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		m, err := g.reg.LookupMsg("", f.GetTypeName())
+		if err != nil {
+			return "", err
+		}
+		if isWellKnownJSON(f.GetTypeName()) {
+			return g.swiftWellKnownFromJS(m.FQMN(), expr)
+		}
+		return fmt.Sprintf("(%s as? [String: Any]).map(%s)", expr, g.swiftConverterName(m, "FromDictionary")), nil
+	case gdescriptor.FieldDescriptorProto_TYPE_ENUM:
+		e, err := g.reg.LookupEnum("", f.GetTypeName())
+		if err != nil {
+			return "", err
+		}
+		name := swiftTypeName(e.File, e.Outers, e.GetName())
+		return fmt.Sprintf("rnGrpcNumber(%s).map({ %s(rawValue: $0.intValue) ?? %s() })", expr, name, name), nil
+	}
+	if accessor, ok := swiftScalars[f.GetType()]; ok {
+		return fmt.Sprintf("rnGrpcNumber(%s)?.%s", expr, accessor), nil
+	}
+	return "", fmt.Errorf("unsupported field type %s", f.GetType())
+}
+
+// swiftToJS returns an expression converting the Swift value expr of f to a
+// JS value.
+func (g *generator) swiftToJS(f *gdescriptor.FieldDescriptorProto, expr string) (string, error) {
+	switch f.GetType() {
+	case gdescriptor.FieldDescriptorProto_TYPE_BYTES:
+		if g.Bytes == "utf8" {
+			return fmt.Sprintf("String(decoding: %s, as: UTF8.self)", expr), nil
+		}
+		return expr + ".base64EncodedString()", nil
+	case gdescriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		m, err := g.reg.LookupMsg("", f.GetTypeName())
+		if err != nil {
+			return "", err
+		}
+		if isWellKnownJSON(f.GetTypeName()) {
+			return g.swiftWellKnownToJS(m.FQMN(), expr)
+		}
+		return fmt.Sprintf("%s(%s)", g.swiftConverterName(m, "ToDictionary"), expr), nil
+	case gdescriptor.FieldDescriptorProto_TYPE_ENUM:
+		return expr + ".rawValue", nil
+	case gdescriptor.FieldDescriptorProto_TYPE_INT64,
+		gdescriptor.FieldDescriptorProto_TYPE_SINT64,
+		gdescriptor.FieldDescriptorProto_TYPE_SFIXED64,
+		gdescriptor.FieldDescriptorProto_TYPE_UINT64,
+		gdescriptor.FieldDescriptorProto_TYPE_FIXED64:
+		if g.Int64 != "number" {
+			return fmt.Sprintf("String(%s)", expr), nil
+		}
+	}
+	return expr, nil
+}
+
+// swiftWellKnownFromJS returns an optional expression converting the JS value
+// expr to the well-known type typeName. Timestamps and durations reuse the JSON
+// support of SwiftProtobuf.
+func (g *generator) swiftWellKnownFromJS(typeName, expr string) (string, error) {
+	swiftName := "Google_Protobuf_" + wellKnownName(typeName)
+	switch {
+	case typeName == wktTimestamp && g.Timestamp == "ms":
+		return fmt.Sprintf("rnGrpcNumber(%s).map({ %s(timeIntervalSince1970: $0.doubleValue / 1000) })", expr, swiftName), nil
+	case typeName == wktTimestamp, typeName == wktDuration, typeName == wktFieldMask:
+		g.useHelper("json", swiftJSONHelpers)
+		return fmt.Sprintf("rnGrpcFromJSON(%s.self, %s)", swiftName, expr), nil
+	case typeName == wktStruct:
+		g.useHelper("struct", swiftStructHelpers)
+		return fmt.Sprintf("(%s as? [String: Any]).map(rnGrpcStructFromJS)", expr), nil
+	case typeName == wktListValue:
+		g.useHelper("struct", swiftStructHelpers)
+		return fmt.Sprintf("(%s as? [Any]).map(rnGrpcListValueFromJS)", expr), nil
+	case typeName == wktValue:
+		g.useHelper("struct", swiftStructHelpers)
+		return fmt.Sprintf("(%s as Any?).map(rnGrpcValueFromJS)", expr), nil
+	case typeName == wktAny:
+		return fmt.Sprintf("(%s as? [String: Any]).flatMap(rnGrpcAnyFromJS)", expr), nil
+	}
+	valueField, err := g.wrapperValueField(typeName)
+	if err != nil {
+		return "", err
+	}
+	value, err := g.swiftFromJS(valueField, expr)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("(%s).map({ %s($0) })", value, swiftName), nil
+}
+
+// swiftWellKnownToJS returns an expression converting the well-known type
+// typeName expr to a JS value.
+func (g *generator) swiftWellKnownToJS(typeName, expr string) (string, error) {
+	switch {
+	case typeName == wktTimestamp && g.Timestamp == "ms":
+		return expr + ".timeIntervalSince1970 * 1000", nil
+	case typeName == wktTimestamp, typeName == wktDuration, typeName == wktFieldMask:
+		g.useHelper("json", swiftJSONHelpers)
+		return fmt.Sprintf("rnGrpcToJSON(%s)", expr), nil
+	case isStructType(typeName):
+		g.useHelper("struct", swiftStructHelpers)
+		return fmt.Sprintf("rnGrpc%sToJS(%s)", wellKnownName(typeName), expr), nil
+	case typeName == wktAny:
+		return fmt.Sprintf("rnGrpcAnyToJS(%s)", expr), nil
+	}
+	valueField, err := g.wrapperValueField(typeName)
+	if err != nil {
+		return "", err
+	}
+	return g.swiftToJS(valueField, expr+".value")
+}
+
+// writeSwiftAnyRegistry writes the functions packing and unpacking the
+// messages of the file, and the well-known types, to and from the JSON form
+// of google.protobuf.Any.
+func (g *generator) writeSwiftAnyRegistry(file *descriptor.File, buf io.Writer) error {
+	messages := g.reachableMessages(file)
+	wkts := anyWellKnownTypes(messages)
+	fmt.Fprint(buf, "\nfileprivate func rnGrpcAnyFromJS(_ dict: [String: Any]) -> Google_Protobuf_Any? {\n    switch dict[\"@type\"] as? String {\n")
+	for _, m := range messages {
+		fmt.Fprintf(buf, "    case %q?:\n        return try? Google_Protobuf_Any(message: %s(dict))\n", anyTypeURL(m), g.swiftConverterName(m, "FromDictionary"))
+	}
+	for _, t := range wkts {
+		if t == wktEmpty {
+			fmt.Fprintf(buf, "    case %q?:\n        return try? Google_Protobuf_Any(message: Google_Protobuf_Empty())\n", wellKnownTypeURL(t))
+			continue
+		}
+		from, err := g.swiftWellKnownFromJS(t, `dict["value"]`)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "    case %q?:\n        return (%s).flatMap({ try? Google_Protobuf_Any(message: $0) })\n", wellKnownTypeURL(t), from)
+	}
+	fmt.Fprint(buf, "    default:\n        return nil\n    }\n}\n")
+
+	fmt.Fprint(buf, "\nfileprivate func rnGrpcAnyToJS(_ any: Google_Protobuf_Any) -> [String: Any] {\n    var out = [String: Any]()\n    switch any.typeURL {\n")
+	for _, m := range messages {
+		fmt.Fprintf(buf, "    case %q:\n        if let msg = try? %s(unpackingAny: any) {\n            out = %s(msg)\n        }\n",
+			anyTypeURL(m), g.swiftMessageName(m), g.swiftConverterName(m, "ToDictionary"))
+	}
+	for _, t := range wkts {
+		if t == wktEmpty {
+			fmt.Fprintf(buf, "    case %q:\n        if (try? Google_Protobuf_Empty(unpackingAny: any)) != nil {\n            out[\"value\"] = [String: Any]()\n        }\n", wellKnownTypeURL(t))
+			continue
+		}
+		to, err := g.swiftWellKnownToJS(t, "msg")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "    case %q:\n        if let msg = try? Google_Protobuf_%s(unpackingAny: any) {\n            out[\"value\"] = %s\n        }\n",
+			wellKnownTypeURL(t), wellKnownName(t), to)
+	}
+	fmt.Fprint(buf, "    default:\n        break\n    }\n    out[\"@type\"] = any.typeURL\n    return out\n}\n")
+	return nil
+}
+
+func (g *generator) swiftFieldFromDictionary(f *descriptor.Field, buf io.Writer) error {
+	name := swiftFieldName(f.FieldDescriptorProto)
+	key := fmt.Sprintf("dict[%q]", f.GetJsonName())
+	if g.isMapField(f.FieldDescriptorProto) {
+		valueField, err := g.mapValueField(f.FieldDescriptorProto)
+		if err != nil {
+			return err
+		}
+		item, err := g.swiftFromJS(valueField, "$0")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "    if let map = %s as? [String: Any] {\n        msg.%s = map.compactMapValues { %s }\n    }\n", key, name, item)
+	} else if f.GetLabel() == gdescriptor.FieldDescriptorProto_LABEL_REPEATED {
+		item, err := g.swiftFromJS(f.FieldDescriptorProto, "$0")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "    if let list = %s as? [Any] {\n        msg.%s = list.compactMap { %s }\n    }\n", key, name, item)
+	} else {
+		item, err := g.swiftFromJS(f.FieldDescriptorProto, key)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "    if let value = %s {\n        msg.%s = value\n    }\n", item, name)
+	}
+	return nil
+}
+
+func (g *generator) swiftFieldToDictionary(f *descriptor.Field, value string, buf io.Writer) error {
+	key := fmt.Sprintf("out[%q]", f.GetJsonName())
+	if g.isMapField(f.FieldDescriptorProto) {
+		valueField, err := g.mapValueField(f.FieldDescriptorProto)
+		if err != nil {
+			return err
+		}
+		item, err := g.swiftToJS(valueField, "$0")
+		if err != nil {
+			return err
+		}
+		if item == "$0" {
+			fmt.Fprintf(buf, "    %s = %s\n", key, value)
+		} else {
+			fmt.Fprintf(buf, "    %s = %s.mapValues { %s }\n", key, value, item)
+		}
+	} else if f.GetLabel() == gdescriptor.FieldDescriptorProto_LABEL_REPEATED {
+		item, err := g.swiftToJS(f.FieldDescriptorProto, "$0")
+		if err != nil {
+			return err
+		}
+		if item == "$0" {
+			fmt.Fprintf(buf, "    %s = %s\n", key, value)
+		} else {
+			fmt.Fprintf(buf, "    %s = %s.map { %s }\n", key, value, item)
+		}
+	} else {
+		item, err := g.swiftToJS(f.FieldDescriptorProto, value)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "    %s = %s\n", key, item)
+	}
+	return nil
+}
+
+// generateSwiftConverters writes the file private functions converting
+// between m and [String: Any], mirroring readableMapToBuilder and
+// protoMessageToReactMap of the Java modules.
+func (g *generator) generateSwiftConverters(m *descriptor.Message, buf io.Writer) error {
+	typeName := g.swiftMessageName(m)
+	fmt.Fprintf(buf, "\nfileprivate func %s(_ dict: [String: Any]) -> %s {\n    var msg = %s()\n", g.swiftConverterName(m, "FromDictionary"), typeName, typeName)
+	for _, f := range m.Fields {
+		if err := g.swiftFieldFromDictionary(f, buf); err != nil {
+			return err
+		}
+	}
+	fmt.Fprint(buf, "    return msg\n}\n")
+
+	fmt.Fprintf(buf, "\nfileprivate func %s(_ msg: %s) -> [String: Any] {\n    var out = [String: Any]()\n", g.swiftConverterName(m, "ToDictionary"), typeName)
+	for _, f := range m.Fields {
+		if f.OneofIndex != nil {
+			continue
+		}
+		value := "msg." + swiftFieldName(f.FieldDescriptorProto)
+		if f.GetType() == gdescriptor.FieldDescriptorProto_TYPE_MESSAGE && f.GetLabel() != gdescriptor.FieldDescriptorProto_LABEL_REPEATED {
+			var inner bytes.Buffer
+			if err := g.swiftFieldToDictionary(f, value, &inner); err != nil {
+				return err
+			}
+			fmt.Fprintf(buf, "    if msg.has%s {\n    %s    }", swiftCamelCase(f.GetName(), true), inner.String())
+			if isWellKnownJSON(f.GetTypeName()) {
+				fmt.Fprintf(buf, " else {\n        out[%q] = NSNull()\n    }", f.GetJsonName())
+			}
+			fmt.Fprint(buf, "\n")
+			continue
+		}
+		if err := g.swiftFieldToDictionary(f, value, buf); err != nil {
+			return err
+		}
+	}
+	for oi, o := range m.GetOneofDecl() {
+		fmt.Fprintf(buf, "    switch msg.%s {\n", swiftCamelCase(o.GetName(), false))
+		for _, f := range m.Fields {
+			if f.OneofIndex == nil || f.GetOneofIndex() != int32(oi) {
+				continue
+			}
+			fmt.Fprintf(buf, "    case .%s(let value)?:\n    ", swiftFieldName(f.FieldDescriptorProto))
+			if err := g.swiftFieldToDictionary(f, "value", buf); err != nil {
+				return err
+			}
+		}
+		fmt.Fprint(buf, "    case nil:\n        break\n    }\n")
+	}
+	fmt.Fprint(buf, "    return out\n}\n")
+	return nil
+}
+
+func (g *generator) generateSwiftMethod(m *descriptor.Method, swift io.Writer, bridge io.Writer) error {
+	params := map[string]interface{}{
+		"methodName":    ToJsonName(m.GetName()),
+		"bigName":       strings.Title(ToJsonName(m.GetName())),
+		"rpcName":       swiftCamelCase(m.GetName(), false),
+		"fromDict":      g.swiftConverterName(m.RequestType, "FromDictionary"),
+		"toDict":        g.swiftConverterName(m.ResponseType, "ToDictionary"),
+		"success":       ".success(let response)",
+		"result":        g.swiftConverterName(m.ResponseType, "ToDictionary") + "(response)",
+		"requestType":   g.swiftMessageName(m.RequestType),
+		"responseType":  g.swiftMessageName(m.ResponseType),
+		"serviceModule": m.Service.GetName() + "Module",
+	}
+	if isEmptyMessage(m.ResponseType) {
+		params["success"], params["result"] = ".success", "nil"
+	}
+	var temp, extern string
+	switch {
+	case !m.GetClientStreaming() && !m.GetServerStreaming():
+		temp = `
+    @objc({{methodName}}:options:resolver:rejecter:)
+    func {{methodName}}(_ dict: [String: Any], options: [String: Any]?, resolver resolve: @escaping RCTPromiseResolveBlock, rejecter reject: @escaping RCTPromiseRejectBlock) {
+        let withMetadata = options?["withMetadata"] as? Bool ?? false
+        let requestID = options?["requestId"] as? String
+        if let requestID = requestID, hasCall(requestID, unary: true) {
+            rnGrpcRejectInFlight(reject, requestID)
+            return
+        }
+        let call = client().{{rpcName}}({{fromDict}}(dict), callOptions: callOptions(options))
+        if let requestID = requestID {
+            registerCall(requestID, unary: true) { call.cancel(promise: nil) }
+        }
+        call.response.whenComplete { [weak self] result in
+            if let requestID = requestID {
+                _ = self?.unregisterCall(requestID, unary: true)
+            }
+            switch result {
+            case {{success}}:
+                if withMetadata {
+                    self?.resolveWithMetadata({{result}}, headers: call.initialMetadata, trailers: call.trailingMetadata, resolve: resolve)
+                } else {
+                    resolve({{result}})
+                }
+            case .failure(let error):
+                call.trailingMetadata.whenComplete { trailers in
+                    rnGrpcReject(reject, error, trailers: try? trailers.get())
+                }
+            }
+        }
+    }
+`
+		extern = `RCT_EXTERN_METHOD({{methodName}}:(NSDictionary *)in options:(NSDictionary *)options resolver:(RCTPromiseResolveBlock)resolve rejecter:(RCTPromiseRejectBlock)reject)
+`
+	case !m.GetClientStreaming() && m.GetServerStreaming():
+		temp = `
+    @objc({{methodName}}:options:resolver:rejecter:)
+    func {{methodName}}(_ dict: [String: Any], options: [String: Any]?, resolver resolve: @escaping RCTPromiseResolveBlock, rejecter reject: @escaping RCTPromiseRejectBlock) {
+        let eventID = UUID().uuidString
+        let call = client().{{rpcName}}({{fromDict}}(dict), callOptions: callOptions(options)) { [weak self] response in
+            self?.emit(eventID, done: false, data: {{toDict}}(response), error: nil)
+        }
+        registerCall(eventID, unary: false) { call.cancel(promise: nil) }
+        call.status.whenComplete { [weak self] result in
+            // Calls cancelled from javascript already sent their last event.
+            guard let self = self, self.unregisterCall(eventID, unary: false) != nil else {
+                return
+            }
+            call.trailingMetadata.whenComplete { trailers in
+                self.finish(eventID, result, trailers: try? trailers.get())
+            }
+        }
+        resolve(eventID)
+    }
+`
+		extern = `RCT_EXTERN_METHOD({{methodName}}:(NSDictionary *)in options:(NSDictionary *)options resolver:(RCTPromiseResolveBlock)resolve rejecter:(RCTPromiseRejectBlock)reject)
+`
+	case m.GetClientStreaming() && !m.GetServerStreaming():
+		temp = `
+    @objc(start{{bigName}}:resolver:rejecter:)
+    func start{{bigName}}(_ options: [String: Any]?, resolver resolve: @escaping RCTPromiseResolveBlock, rejecter reject: @escaping RCTPromiseRejectBlock) {
+        let id = UUID().uuidString
+        let call = client().{{rpcName}}(callOptions: callOptions(options))
+        streamsLock.lock()
+        {{methodName}}Calls[id] = call
+        if options?["withMetadata"] as? Bool ?? false {
+            {{methodName}}WithMetadata.insert(id)
+        }
+        streamsLock.unlock()
+        call.response.whenComplete { [weak self] _ in
+            guard let self = self else {
+                return
+            }
+            self.streamsLock.lock()
+            self.{{methodName}}Calls[id] = nil
+            self.{{methodName}}WithMetadata.remove(id)
+            self.halfClosed.remove(id)
+            self.streamsLock.unlock()
+        }
+        resolve(id)
+    }
+
+    // Sends dict to the stream, or half-closes it after sending dict, if any,
+    // with "complete", or cancels it with "error". "complete" resolves the
+    // response, the other actions resolve once the message is queued and
+    // reject once the stream is closed, or half-closed but for "error".
+    @objc({{methodName}}:action:event:resolver:rejecter:)
+    func {{methodName}}(_ id: String, action: String, event dict: [String: Any]?, resolver resolve: @escaping RCTPromiseResolveBlock, rejecter reject: @escaping RCTPromiseRejectBlock) {
+        streamsLock.lock()
+        let call = {{methodName}}Calls[id]
+        let wasHalfClosed = halfClosed.contains(id)
+        let withMetadata = {{methodName}}WithMetadata.contains(id)
+        if call != nil {
+            switch action {
+            case "error":
+                {{methodName}}Calls[id] = nil
+                {{methodName}}WithMetadata.remove(id)
+                halfClosed.remove(id)
+            case "complete":
+                halfClosed.insert(id)
+            default:
+                break
+            }
+        }
+        streamsLock.unlock()
+        guard let call = call else {
+            reject("not_found", "stream \(id) is not open", nil)
+            return
+        }
+        if wasHalfClosed && action != "error" {
+            reject("half_closed", "stream \(id) is half-closed", nil)
+            return
+        }
+        switch action {
+        case "complete":
+            if let dict = dict {
+                call.sendMessage({{fromDict}}(dict), promise: nil)
+            }
+            call.sendEnd(promise: nil)
+            call.response.whenComplete { [weak self] result in
+                switch result {
+                case {{success}}:
+                    if withMetadata {
+                        self?.resolveWithMetadata({{result}}, headers: call.initialMetadata, trailers: call.trailingMetadata, resolve: resolve)
+                    } else {
+                        resolve({{result}})
+                    }
+                case .failure(let error):
+                    call.trailingMetadata.whenComplete { trailers in
+                        rnGrpcReject(reject, error, trailers: try? trailers.get())
+                    }
+                }
+            }
+        case "error":
+            call.cancel(promise: nil)
+            resolve(nil)
+        default:
+            guard let dict = dict else {
+                rnGrpcRejectInvalid(reject, "stream \(id) needs a message to send")
+                return
+            }
+            call.sendMessage({{fromDict}}(dict), promise: nil)
+            resolve(nil)
+        }
+    }
+`
+		extern = `RCT_EXTERN_METHOD(start{{bigName}}:(NSDictionary *)options resolver:(RCTPromiseResolveBlock)resolve rejecter:(RCTPromiseRejectBlock)reject)
+RCT_EXTERN_METHOD({{methodName}}:(NSString *)id action:(NSString *)action event:(NSDictionary *)in resolver:(RCTPromiseResolveBlock)resolve rejecter:(RCTPromiseRejectBlock)reject)
+`
+	default:
+		temp = `
+    @objc(start{{bigName}}:resolver:rejecter:)
+    func start{{bigName}}(_ options: [String: Any]?, resolver resolve: @escaping RCTPromiseResolveBlock, rejecter reject: @escaping RCTPromiseRejectBlock) {
+        let id = UUID().uuidString
+        let call = client().{{rpcName}}(callOptions: callOptions(options)) { [weak self] response in
+            self?.emit(id, done: false, data: {{toDict}}(response), error: nil)
+        }
+        streamsLock.lock()
+        {{methodName}}Calls[id] = call
+        streamsLock.unlock()
+        call.status.whenComplete { [weak self] result in
+            guard let self = self else {
+                return
+            }
+            self.streamsLock.lock()
+            self.{{methodName}}Calls[id] = nil
+            self.halfClosed.remove(id)
+            let reason = self.cancelReasons.removeValue(forKey: id)
+            self.streamsLock.unlock()
+            call.trailingMetadata.whenComplete { trailers in
+                // Streams ended from javascript fail with the status it gave.
+                self.finish(id, reason.map { Result<GRPCStatus, Error>.success($0) } ?? result, trailers: try? trailers.get())
+            }
+        }
+        resolve(id)
+    }
+
+    // Sends dict to the stream, or half-closes it after sending dict, if any,
+    // with "complete", or cancels it with the code and message of dict with
+    // "error". The promise resolves once the message is written and rejects
+    // once the stream is closed, or half-closed but for "error". Half-closed
+    // streams stay registered until the server ends them.
+    @objc({{methodName}}:action:event:resolver:rejecter:)
+    func {{methodName}}(_ id: String, action: String, event dict: [String: Any]?, resolver resolve: @escaping RCTPromiseResolveBlock, rejecter reject: @escaping RCTPromiseRejectBlock) {
+        streamsLock.lock()
+        let call = {{methodName}}Calls[id]
+        let wasHalfClosed = halfClosed.contains(id)
+        if call != nil {
+            switch action {
+            case "error":
+                {{methodName}}Calls[id] = nil
+                halfClosed.remove(id)
+                cancelReasons[id] = rnGrpcStatusFromJS(dict)
+            case "complete":
+                halfClosed.insert(id)
+            default:
+                break
+            }
+        }
+        streamsLock.unlock()
+        guard let call = call else {
+            reject("not_found", "stream \(id) is not open", nil)
+            return
+        }
+        if wasHalfClosed && action != "error" {
+            reject("half_closed", "stream \(id) is half-closed", nil)
+            return
+        }
+        let written: EventLoopFuture<Void>
+        switch action {
+        case "complete":
+            if let dict = dict {
+                call.sendMessage({{fromDict}}(dict), promise: nil)
+            }
+            written = call.sendEnd()
+        case "error":
+            call.cancel(promise: nil)
+            resolve(nil)
+            return
+        default:
+            written = call.sendMessage({{fromDict}}(dict ?? [:]))
+        }
+        written.whenComplete { result in
+            switch result {
+            case .success:
+                resolve(nil)
+            case .failure(let error):
+                rnGrpcReject(reject, error, trailers: nil)
+            }
+        }
+    }
+`
+		extern = `RCT_EXTERN_METHOD(start{{bigName}}:(NSDictionary *)options resolver:(RCTPromiseResolveBlock)resolve rejecter:(RCTPromiseRejectBlock)reject)
+RCT_EXTERN_METHOD({{methodName}}:(NSString *)id action:(NSString *)action event:(NSDictionary *)in resolver:(RCTPromiseResolveBlock)resolve rejecter:(RCTPromiseRejectBlock)reject)
+`
+	}
+	if _, err := fasttemplate.Execute(temp, "{{", "}}", swift, params); err != nil {
+		return err
+	}
+	_, err := fasttemplate.Execute(extern, "{{", "}}", bridge, params)
+	return err
+}
+
+// swiftServiceCancel registers the unary calls made with a requestId and the
+// server streaming calls of a module, to be cancelled from javascript. Calls
+// end on the event loops, hence the lock.
+const swiftServiceCancel = `
+    private func registerCall(_ id: String, unary: Bool, cancel: @escaping () -> Void) {
+        callsLock.lock()
+        defer { callsLock.unlock() }
+        if unary {
+            unaryCalls[id] = cancel
+        } else {
+            calls[id] = cancel
+        }
+    }
+
+    private func hasCall(_ id: String, unary: Bool) -> Bool {
+        callsLock.lock()
+        defer { callsLock.unlock() }
+        return (unary ? unaryCalls[id] : calls[id]) != nil
+    }
+
+    private func unregisterCall(_ id: String, unary: Bool) -> (() -> Void)? {
+        callsLock.lock()
+        defer { callsLock.unlock() }
+        return unary ? unaryCalls.removeValue(forKey: id) : calls.removeValue(forKey: id)
+    }
+
+    // Cancels the call started under id. Unary calls, made with a requestId,
+    // reject with CANCELLED and streams end with a {done: true, cancelled: true}
+    // event.
+    @objc(cancel:)
+    func cancel(_ id: String) {
+        if let cancel = unregisterCall(id, unary: true) {
+            cancel()
+            return
+        }
+        guard let cancel = unregisterCall(id, unary: false) else {
+            return
+        }
+        cancel()
+        bridge?.enqueueJSCall("RCTDeviceEventEmitter", method: "emit", args: [id, ["done": true, "cancelled": true]], completion: nil)
+    }
+`
+
+// swiftStreamFields declares the open calls of the streaming methods of svc.
+// The module methods all run on the module's method queue, so plain
+// dictionaries are enough.
+func (g *generator) swiftStreamFields(svc *descriptor.Service) string {
+	var buf bytes.Buffer
+	for _, m := range svc.Methods {
+		if !m.GetClientStreaming() {
+			continue
+		}
+		call := "ClientStreamingCall"
+		if m.GetServerStreaming() {
+			call = "BidirectionalStreamingCall"
+		}
+		fmt.Fprintf(&buf, "    private var %sCalls = [String: %s<%s, %s>]()\n", ToJsonName(m.GetName()), call,
+			g.swiftMessageName(m.RequestType), g.swiftMessageName(m.ResponseType))
+		if !m.GetServerStreaming() {
+			fmt.Fprintf(&buf, "    private var %sWithMetadata = Set<String>()\n", ToJsonName(m.GetName()))
+		}
+	}
+	if hasClientStreaming(svc) || hasBiStreaming(svc) {
+		buf.WriteString("    private var cancelReasons = [String: GRPCStatus]()\n    private var halfClosed = Set<String>()\n    private let streamsLock = NSLock()\n")
+	}
+	if hasCancellable(svc) {
+		buf.WriteString("    private var unaryCalls = [String: () -> Void]()\n    private var calls = [String: () -> Void]()\n    private let callsLock = NSLock()\n")
+	}
+	return buf.String()
+}
+
+// writeSwiftCallRegistry writes the invalidate method of svc, which
+// cancels the calls and streams still open, and the method counting the
+// bidirectional streams. Streams leave their
+// table from the event loops when the server ends them, hence streamsLock.
+func (g *generator) writeSwiftCallRegistry(svc *descriptor.Service, buf io.Writer) {
+	var tables, bidi []string
+	for _, m := range svc.Methods {
+		if m.GetClientStreaming() {
+			tables = append(tables, ToJsonName(m.GetName())+"Calls")
+			if m.GetServerStreaming() {
+				bidi = append(bidi, ToJsonName(m.GetName())+"Calls")
+			}
+		}
+	}
+	cancellable := hasCancellable(svc)
+	if !cancellable && len(tables) == 0 {
+		return
+	}
+	fmt.Fprint(buf, "\n    override func invalidate() {\n")
+	if cancellable {
+		fmt.Fprint(buf, `        callsLock.lock()
+        let cancels = Array(unaryCalls.values) + Array(calls.values)
+        unaryCalls.removeAll()
+        calls.removeAll()
+        callsLock.unlock()
+        cancels.forEach { $0() }
+`)
+	}
+	if len(tables) > 0 {
+		fmt.Fprint(buf, "        streamsLock.lock()\n")
+		for _, table := range tables {
+			fmt.Fprintf(buf, "        let %s = self.%s.values\n        self.%s.removeAll()\n", table, table, table)
+		}
+		fmt.Fprint(buf, "        streamsLock.unlock()\n")
+		for _, table := range tables {
+			fmt.Fprintf(buf, "        %s.forEach { $0.cancel(promise: nil) }\n", table)
+		}
+	}
+	fmt.Fprint(buf, "        super.invalidate()\n    }\n")
+	if len(bidi) == 0 {
+		return
+	}
+	fmt.Fprintf(buf, `
+    // Resolves the number of open bidirectional streams, for diagnostics.
+    @objc(activeStreamCount:rejecter:)
+    func activeStreamCount(_ resolve: @escaping RCTPromiseResolveBlock, rejecter reject: @escaping RCTPromiseRejectBlock) {
+        streamsLock.lock()
+        defer { streamsLock.unlock() }
+        resolve(%s)
+    }
+`, strings.Join(bidi, ".count + ")+".count")
+}
+
+func (g *generator) generateSwiftFile(file *descriptor.File) (string, string, error) {
+	var swift, bridge bytes.Buffer
+	swift.WriteString(swiftHeader)
+	swift.WriteString(errorHelpers(swiftErrorHelpers, `"`))
+	bridge.WriteString(swiftBridgeHeader)
+
+	for _, msg := range g.reachableMessages(file) {
+		if err := g.generateSwiftConverters(msg, &swift); err != nil {
+			return "", "", err
+		}
+	}
+	if g.usesAny(file) {
+		if err := g.writeSwiftAnyRegistry(file, &swift); err != nil {
+			return "", "", err
+		}
+	}
+	g.writeHelpers(&swift)
+
+	for _, svc := range file.Services {
+		glog.V(1).Infof("Service Name %s", svc.GetName())
+		fasttemplate.Execute(swiftServiceTop, "{{", "}}", &swift, map[string]interface{}{
+			"serviceName": svc.GetName(),
+			"clientType":  swiftPrefix(file) + svc.GetName() + "Client",
+			"fullName":    strings.TrimPrefix(file.GetPackage()+"."+svc.GetName(), "."),
+			"streams":     g.swiftStreamFields(svc),
+		})
+		fmt.Fprintf(&bridge, "\n@interface RCT_EXTERN_REMAP_MODULE(%s, %sModule, RCTEventEmitter)\n", svc.GetName(), svc.GetName())
+		if hasCancellable(svc) {
+			swift.WriteString(swiftServiceCancel)
+			bridge.WriteString("RCT_EXTERN_METHOD(cancel:(NSString *)id)\n")
+		}
+		g.writeSwiftCallRegistry(svc, &swift)
+		if hasBiStreaming(svc) {
+			bridge.WriteString("RCT_EXTERN_METHOD(activeStreamCount:(RCTPromiseResolveBlock)resolve rejecter:(RCTPromiseRejectBlock)reject)\n")
+		}
+		for _, m := range svc.Methods {
+			if err := g.generateSwiftMethod(m, &swift, &bridge); err != nil {
+				return "", "", err
+			}
+		}
+		swift.WriteString("}\n")
+		bridge.WriteString("@end\n")
+	}
+	return swift.String(), bridge.String(), nil
+}
+
+// generateSwift emits an RCTEventEmitter subclass for every service, built on
+// the grpc-swift clients, together with the Objective-C file exporting it to
+// React Native. The modules expect the application to provide a GrpcEngine
+// with a shared instance, channel(forServiceName:), callOptions() and, for the
+// GrpcConfig module, configure(_:options:).
+func (g *generator) generateSwift(targets []*descriptor.File) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	for _, file := range targets {
+		name := file.GetName()
+		base := ToFileName(strings.TrimSuffix(name, filepath.Ext(name))) + "Module"
+		swift, bridge, err := g.generateSwiftFile(file)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(base + ".swift"),
+			Content: proto.String(swift),
+		}, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(base + ".m"),
+			Content: proto.String(bridge),
+		})
+		glog.V(1).Infof("Will emit %s.swift and %s.m", base, base)
+	}
+	return files, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	gdescriptor "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/grpc-ecosystem/grpc-gateway/protoc-gen-grpc-gateway/descriptor"
+)
+
+// Well-known types cross the bridge in their proto3 JSON representation, the
+// same one jsonpb uses, instead of as a map of their fields.
+const (
+	wktTimestamp = ".google.protobuf.Timestamp"
+	wktDuration  = ".google.protobuf.Duration"
+	wktEmpty     = ".google.protobuf.Empty"
+	wktStruct    = ".google.protobuf.Struct"
+	wktValue     = ".google.protobuf.Value"
+	wktListValue = ".google.protobuf.ListValue"
+	wktAny       = ".google.protobuf.Any"
+	wktFieldMask = ".google.protobuf.FieldMask"
+)
+
+// helper is a function or constant emitted once per generated file.
+type helper struct {
+	name string
+	code string
+}
+
+// wktWrappers are the wrapper messages, passed as their nullable value, and
+// the type of that value.
+var wktWrappers = map[string]gdescriptor.FieldDescriptorProto_Type{
+	".google.protobuf.DoubleValue": gdescriptor.FieldDescriptorProto_TYPE_DOUBLE,
+	".google.protobuf.FloatValue":  gdescriptor.FieldDescriptorProto_TYPE_FLOAT,
+	".google.protobuf.Int64Value":  gdescriptor.FieldDescriptorProto_TYPE_INT64,
+	".google.protobuf.UInt64Value": gdescriptor.FieldDescriptorProto_TYPE_UINT64,
+	".google.protobuf.Int32Value":  gdescriptor.FieldDescriptorProto_TYPE_INT32,
+	".google.protobuf.UInt32Value": gdescriptor.FieldDescriptorProto_TYPE_UINT32,
+	".google.protobuf.BoolValue":   gdescriptor.FieldDescriptorProto_TYPE_BOOL,
+	".google.protobuf.StringValue": gdescriptor.FieldDescriptorProto_TYPE_STRING,
+	".google.protobuf.BytesValue":  gdescriptor.FieldDescriptorProto_TYPE_BYTES,
+}
+
+// wktAnyTypes are the well-known types the Any registries pack besides the
+// messages of a file. Like jsonpb, they are passed as {"@type", "value"},
+// value being what a field of the type is passed as.
+var wktAnyTypes = []string{
+	wktTimestamp, wktDuration, wktStruct, wktValue, wktListValue, wktFieldMask, wktEmpty, wktAny,
+	".google.protobuf.DoubleValue", ".google.protobuf.FloatValue", ".google.protobuf.Int64Value",
+	".google.protobuf.UInt64Value", ".google.protobuf.Int32Value", ".google.protobuf.UInt32Value",
+	".google.protobuf.BoolValue", ".google.protobuf.StringValue", ".google.protobuf.BytesValue",
+}
+
+// isWellKnownJSON reports whether values of the message typeName are
+// passed as a plain JS value rather than as a map of their fields.
+func isWellKnownJSON(typeName string) bool {
+	_, wrapper := wktWrappers[typeName]
+	return typeName == wktTimestamp || typeName == wktDuration || wrapper || isStructType(typeName) ||
+		typeName == wktAny
+}
+
+// isStructType reports whether typeName is one of the messages of
+// struct.proto, passed as arbitrary JS objects, arrays and values.
+func isStructType(typeName string) bool {
+	return typeName == wktStruct || typeName == wktValue || typeName == wktListValue
+}
+
+// isEmptyMessage reports whether m is google.protobuf.Empty, which is
+// returned to JS as undefined.
+func isEmptyMessage(m *descriptor.Message) bool {
+	return m.FQMN() == wktEmpty
+}
+
+// anyTypeURL returns the type URL of m packed in a google.protobuf.Any.
+func anyTypeURL(m *descriptor.Message) string {
+	return wellKnownTypeURL(m.FQMN())
+}
+
+// wellKnownTypeURL returns the type URL of the message typeName packed in a
+// google.protobuf.Any.
+func wellKnownTypeURL(typeName string) string {
+	return "type.googleapis.com/" + strings.TrimPrefix(typeName, ".")
+}
+
+// wellKnownName returns the name of the well-known type typeName without its
+// package.
+func wellKnownName(typeName string) string {
+	return strings.TrimPrefix(typeName, ".google.protobuf.")
+}
+
+// wrapperValueField returns the value field of the wrapper message typeName,
+// which the Any registries use without wrappers.proto being imported.
+func (g *generator) wrapperValueField(typeName string) (*gdescriptor.FieldDescriptorProto, error) {
+	t, ok := wktWrappers[typeName]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a wrapper message", typeName)
+	}
+	return &gdescriptor.FieldDescriptorProto{
+		Name:     proto.String("value"),
+		JsonName: proto.String("value"),
+		Number:   proto.Int32(1),
+		Label:    gdescriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     t.Enum(),
+	}, nil
+}
+
+// useHelper records that the code being generated calls the helper name,
+// defined by code. Helpers a helper depends on must be used before it.
+func (g *generator) useHelper(name, code string) {
+	for _, h := range g.helpers {
+		if h.name == name {
+			return
+		}
+	}
+	g.helpers = append(g.helpers, helper{name, code})
+}
+
+// writeHelpers writes the helpers used since the last call, in the order they
+// were first used.
+func (g *generator) writeHelpers(w io.Writer) {
+	for _, h := range g.helpers {
+		io.WriteString(w, h.code)
+	}
+	g.helpers = nil
+}
+
+// wellKnownFiles returns the files defining the well-known JSON types used by the
+// fields of messages.
+func (g *generator) wellKnownFiles(messages []*descriptor.Message) []*descriptor.File {
+	var files []*descriptor.File
+	for _, m := range messages {
+		for _, f := range m.Fields {
+			typeName := f.GetTypeName()
+			if valueField, err := g.mapValueField(f.FieldDescriptorProto); err == nil && g.isMapField(f.FieldDescriptorProto) {
+				typeName = valueField.GetTypeName()
+			}
+			if !isWellKnownJSON(typeName) {
+				continue
+			}
+			if wkt, err := g.reg.LookupMsg("", typeName); err == nil {
+				files = append(files, wkt.File)
+			}
+		}
+	}
+	return files
+}
+
+// anyWellKnownTypes returns the types of wktAnyTypes the Any registry of
+// messages packs as well-known types, those not among messages.
+func anyWellKnownTypes(messages []*descriptor.Message) []string {
+	seen := map[string]bool{}
+	for _, m := range messages {
+		seen[m.FQMN()] = true
+	}
+	var out []string
+	for _, t := range wktAnyTypes {
+		if !seen[t] {
+			out = append(out, t)
+		}
+	}
+	return out
+}